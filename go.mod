@@ -2,10 +2,4 @@ module github.com/tstpierre-tc/lcd1602
 
 go 1.21.6
 
-require (
-	github.com/sirupsen/logrus v1.9.3
-	periph.io/x/conn/v3 v3.7.0
-	periph.io/x/host/v3 v3.8.2
-)
-
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require periph.io/x/conn/v3 v3.7.0
@@ -6,16 +6,20 @@ Thanks to Dave Cheney for figuring out the registers!
 package lcd1602
 
 import (
-	"encoding/binary"
+	"context"
+	"errors"
 
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 
-	log "github.com/sirupsen/logrus"
-	"periph.io/x/conn/v3"
 	"time"
-	//	"periph.io/x/conn/v3/gpio"
+
+	"periph.io/x/conn/v3/gpio"
 	"periph.io/x/conn/v3/i2c"
-	"periph.io/x/conn/v3/mmr"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
 )
 
 const (
@@ -26,6 +30,7 @@ const (
 	CMD_Display_Control      = 0x08
 	CMD_Cursor_Display_Shift = 0x10
 	CMD_Function_Set         = 0x20
+	CMD_CGRAM_Set            = 0x40
 	CMD_DDRAM_Set            = 0x80
 
 	// Options
@@ -50,148 +55,1159 @@ const (
 	BACKLIGHT = 3
 )
 
+// Dev is a handle to an HD44780 character display.
+//
+// Dev is safe for concurrent use. Every public method holds an internal lock
+// for its whole bus sequence, so a multi-character Write keeps the lock
+// through its CharDelay sleeps and is never interleaved with another call.
 type Dev struct {
+	mu              sync.Mutex
 	isSPI           bool
 	displayEnable   bool
 	backlight_state bool
 	cursor          bool
 	blink           bool
 	displayShift    bool
-	shiftRight      bool
-	c               mmr.Dev8
+	shiftRight      bool                   // cursor decrements: OPT_Increment is clear
+	addr            byte                   // DDRAM address counter as far as we know it
+	filled          lineEnd                // line the last data write ran off the end of, see writePosition
+	shift           byte                   // cells the display window has moved left to right over DDRAM
+	pwmStop         chan struct{}          // closed to stop the backlight PWM loop
+	cgram           [CGRAMSlots]cgramOwner // who loaded each CGRAM slot
+	scratchCell     [CGRAMSlots]byte       // DDRAM address showing each WriteGlyph slot
+	shown           [][]byte               // what the visible cells hold, per line
+	batch           [][]byte               // pending frame while batching, else nil
+	ring            *captureRing           // recent port writes, if Opts.CaptureBytes is set
+	ctrl            byte                   // controller the cursor is on: 0, or 1 for lines 3-4 with Enable2Pin
+	all             bool                   // send to both controllers
+	contrast        *i2c.Dev               // contrast DAC, if Opts.ContrastAddr is set
+	input           *i2c.Dev               // button expander, if Opts.InputAddr is set
+	saver           *ScreensaverHandle     // running Screensaver, if any
+	closed          bool                   // set by Close
+	bgMu            sync.Mutex             // guards bg and bgNext
+	bg              map[int]func()         // stops for running background goroutines
+	bgNext          int
+	p               port
+	pins            PinMap
 	opts            Opts
 }
 
 func (d *Dev) String() string {
-	return fmt.Sprintf("lcd1602{%s}", d.c.Conn)
+	return fmt.Sprintf("lcd1602{%s}", d.p)
+}
+
+// Status describes the driver's idea of the display over several lines:
+// geometry and transport, the display, backlight, cursor and blink
+// switches, the entry mode and display shift, the tracked cursor and the
+// CGRAM slots in use. It is read from cached state without touching the
+// bus, so it can be logged when a display misbehaves.
+func (d *Dev) Status() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	onOff := func(v bool) string {
+		if v {
+			return "on"
+		}
+		return "off"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %dx%d", d, d.opts.Cols, d.opts.Lines)
+	if _, ok := d.p.(*i2cPort); ok {
+		// I2CAddr is 0 when the default was taken.
+		addr, _ := d.opts.i2cAddr()
+		fmt.Fprintf(&b, " at %#x", addr)
+	}
+	if d.batch != nil {
+		b.WriteString(", batching")
+	}
+	fmt.Fprintf(&b, "\ndisplay %s, backlight %s, cursor %s, blink %s\n",
+		onOff(d.displayEnable), onOff(d.backlight_state), onOff(d.cursor), onOff(d.blink))
+	direction := "increment"
+	if d.shiftRight {
+		direction = "decrement"
+	}
+	fmt.Fprintf(&b, "entry mode %s, display shift %s, shifted by %d\n", direction, onOff(d.displayShift), d.shift)
+	if line, col, ok := d.position(); ok {
+		fmt.Fprintf(&b, "cursor at line %d col %d", line, col)
+	} else {
+		b.WriteString("cursor off screen")
+	}
+	fmt.Fprintf(&b, ", DDRAM %#02x\nCGRAM", d.addr)
+	used := false
+	for slot, o := range d.cgram {
+		if o != cgramFree {
+			fmt.Fprintf(&b, " %d:%s", slot, o)
+			used = true
+		}
+	}
+	if !used {
+		b.WriteString(" all free")
+	}
+	return b.String()
 }
 
 // NewI2C returns a new device that communicates over I²C
 //
 // Use default options if nil is used.
 func NewI2C(b i2c.Bus, opts *Opts) (*Dev, error) {
+	return NewI2CContext(context.Background(), b, opts)
+}
+
+// NewI2CContext is like NewI2C but gives up on the init sequence, returning
+// ctx.Err(), as soon as ctx is done.
+//
+// Use it with a deadline when probing addresses that may have nothing
+// attached.
+func NewI2CContext(ctx context.Context, b i2c.Bus, opts *Opts) (*Dev, error) {
+	if b == nil {
+		return nil, errors.New("lcd1602: nil I²C bus")
+	}
 	if opts == nil {
 		opts = &DefaultOpts
 	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
 	addr, err := opts.i2cAddr()
 	if err != nil {
-		return nil, fmt.Errorf("lcd1602 %x: %v", addr, err)
+		return nil, fmt.Errorf("lcd1602 %x: %w", opts.I2CAddr, err)
+	}
+	c := &i2c.Dev{Bus: b, Addr: addr}
+	// Reading the expander leaves its pins alone, so this is safe even when
+	// attaching with SkipInit.
+	if err := c.Tx(nil, make([]byte, 1)); err != nil {
+		return nil, fmt.Errorf("lcd1602: no device responded at %#x (is it %#x?): %w", addr, otherExpanderAddr(addr), err)
 	}
-	d, err := makeDev(&i2c.Dev{Bus: b, Addr: addr}, false, opts)
+	d, err := makeDev(ctx, &i2cPort{c: c}, false, opts)
 	if err != nil {
 		return nil, err
 	}
+	if opts.ContrastAddr != 0 {
+		d.contrast = &i2c.Dev{Bus: b, Addr: opts.ContrastAddr}
+	}
+	if opts.InputAddr != 0 {
+		d.input = &i2c.Dev{Bus: b, Addr: opts.InputAddr}
+		// Writing 1s makes every PCF8574 pin a weak pull-up input.
+		if err := d.input.Tx([]byte{0xFF}, nil); err != nil {
+			return nil, fmt.Errorf("lcd1602: no input expander at %#x: %w", opts.InputAddr, err)
+		}
+	}
 	return d, nil
 }
 
-// Halt is a noop for the cap1xxx.
+// otherExpanderAddr returns the address with the same A0-A2 jumpers on the
+// other expander variant: PCF8574 backpacks answer at 0x20-0x27 and
+// PCF8574A ones at 0x38-0x3F.
+func otherExpanderAddr(addr uint16) uint16 {
+	if addr >= 0x38 {
+		return addr - 0x18
+	}
+	return addr + 0x18
+}
+
+// DetectI2C probes the PCF8574 (0x20-0x27) and PCF8574A (0x38-0x3F)
+// address ranges on b and returns the addresses that acknowledge.
+//
+// Each probe reads one byte, as NewI2C does, so nothing is written to a
+// backpack or to any other device that answers in these ranges.
+func DetectI2C(b i2c.Bus) ([]uint16, error) {
+	if b == nil {
+		return nil, errors.New("lcd1602: nil bus")
+	}
+	var found []uint16
+	for _, base := range []uint16{0x20, 0x38} {
+		for addr := base; addr < base+8; addr++ {
+			if err := b.Tx(addr, nil, make([]byte, 1)); err == nil {
+				found = append(found, addr)
+			}
+		}
+	}
+	return found, nil
+}
+
+// NewSPI returns a new device that communicates over SPI through a shift
+// register backpack.
+//
+// Use default options if nil is used. I2CAddr is ignored.
+func NewSPI(p spi.Port, opts *Opts) (*Dev, error) {
+	if p == nil {
+		return nil, errors.New("lcd1602: nil SPI port")
+	}
+	if opts == nil {
+		opts = &DefaultOpts
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	c, err := p.Connect(1*physic.MegaHertz, spi.Mode0, 8)
+	if err != nil {
+		return nil, fmt.Errorf("lcd1602: %v", err)
+	}
+	return makeDev(context.Background(), &spiPort{c: c}, true, opts)
+}
+
+// GPIOPins are the GPIO pins an HD44780 is wired to directly, without a
+// backpack. RW, Backlight and E2 may be left nil: tie RW to ground, and
+// SupportsRead must then be off. E2 is the second enable of a 40x4 module,
+// see Opts.Enable2Pin.
+//
+// Reads drive no pin, but the controller returns 5V levels on D4-D7, so
+// only enable SupportsRead on a 5V tolerant GPIO or through level shifters.
+type GPIOPins struct {
+	RS, RW, EN     gpio.PinIO
+	D4, D5, D6, D7 gpio.PinIO
+	Backlight      gpio.PinIO
+	E2             gpio.PinIO
+}
+
+// NewGPIO returns a new device driven straight from GPIO pins. The pins
+// stand in for the bits of Opts.PinMap, so PinMap only needs setting when
+// E2 is used and the bit given as Enable2Pin clashes with it.
+//
+// Use default options if nil is used. I2CAddr is ignored.
+func NewGPIO(pins GPIOPins, opts *Opts) (*Dev, error) {
+	if opts == nil {
+		opts = &DefaultOpts
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	for i, pin := range []gpio.PinIO{pins.RS, pins.EN, pins.D4, pins.D5, pins.D6, pins.D7} {
+		if pin == nil {
+			return nil, fmt.Errorf("lcd1602: GPIOPins.%s is nil", [...]string{"RS", "EN", "D4", "D5", "D6", "D7"}[i])
+		}
+	}
+	if pins.RW == nil && opts.SupportsRead {
+		return nil, errors.New("lcd1602: SupportsRead needs GPIOPins.RW")
+	}
+	if (pins.E2 == nil) != (opts.Enable2Pin == 0) {
+		return nil, errors.New("lcd1602: GPIOPins.E2 and Opts.Enable2Pin go together")
+	}
+	m := opts.pinMap()
+	p := &gpioPort{
+		en:   1 << m.EN,
+		data: 1<<m.D4 | 1<<m.D5 | 1<<m.D6 | 1<<m.D7,
+	}
+	p.pins[m.RS], p.pins[m.EN] = pins.RS, pins.EN
+	p.pins[m.D4], p.pins[m.D5], p.pins[m.D6], p.pins[m.D7] = pins.D4, pins.D5, pins.D6, pins.D7
+	p.pins[m.Backlight] = pins.Backlight
+	if pins.RW != nil {
+		p.pins[m.RW] = pins.RW
+		p.rw = 1 << m.RW
+	}
+	if pins.E2 != nil {
+		if opts.Enable2Pin == m.RW {
+			p.rw = 0
+		}
+		p.pins[opts.Enable2Pin] = pins.E2
+		p.en |= 1 << opts.Enable2Pin
+	}
+	return makeDev(context.Background(), p, false, opts)
+}
+
+// Halt blanks the display: it stops the background writers (scrolls,
+// spinners, AutoShift, the screensaver and the backlight PWM), clears the
+// screen, switches the controller's display output off and then turns the
+// backlight off. Every step is tried and all their errors are returned
+// together.
+//
+// Halt is idempotent: each step leaves the same state however often it
+// runs, so it is safe from both a deferred cleanup and a signal handler, and
+// a Halt after SetDisplay or SetBacklight turned the panel back on blanks it
+// again.
+//
+// Halt is kept separate from Close because it leaves the Dev usable: Reset
+// brings the panel back. Close halts the same way and then retires the Dev
+// for good.
 func (d *Dev) Halt() error {
-	// TODO blank the screen and turn off the backlight
-	d.Clear()
-	d.SetBacklight(false)
+	d.stopBackground()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.halt()
+}
+
+// Close halts the display as Halt does and then closes the Dev: any later
+// call that would touch the bus fails with an error wrapping ErrClosed.
+// Later calls to Close return nil.
+func (d *Dev) Close() error {
+	d.stopBackground()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	err := d.halt()
+	d.closed = true
+	return err
+}
+
+func (d *Dev) halt() error {
+	d.stopPWM()
+	var errs []error
+	if err := d.clear(); err != nil {
+		errs = append(errs, fmt.Errorf("clear: %w", err))
+	}
+	d.displayEnable = false
+	if err := d.writeDisplaySwitch(); err != nil {
+		errs = append(errs, fmt.Errorf("display off: %w", err))
+	}
+	if err := d.setBacklight(false); err != nil {
+		errs = append(errs, fmt.Errorf("backlight off: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("lcd1602: halt: %w", err)
+	}
 	return nil
 }
 
-func (d *Dev) SetBacklight(on bool) {
-	d.c.WriteUint8(0, pinInterpret(BACKLIGHT, 0x00, on))
-	d.backlight_state = on
+// SetBacklight switches the backlight on or off. It waits for any write in
+// progress, whose nibbles all carry the backlight bit it started with, so
+// the change takes effect between characters, never within one.
+func (d *Dev) SetBacklight(on bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopPWM()
+	return d.setBacklight(on)
 }
 
-func (d *Dev) Clear() {
-	d.command(CMD_Clear_Display)
+// Clear blanks the screen and moves the cursor home. The entry mode set
+// with SetEntryMode survives: it is sent again after the clear, which
+// otherwise resets it.
+func (d *Dev) Clear() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clear()
 }
 
-func (d *Dev) Home() {
-	d.command(CMD_Return_Home)
+func (d *Dev) Home() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.home()
 }
 
+// SetPosition moves the cursor to pos (0 based) on line (1 based).
 func (d *Dev) SetPosition(line, pos byte) error {
-	if line > d.opts.Lines {
-		return fmt.Errorf("lcd1602 %x: device does not support %d lines", line, d.opts.I2CAddr)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setPosition(line, pos)
+}
+
+func (d *Dev) Write(buf []byte) (int, error) {
+	return d.WriteContext(context.Background(), buf)
+}
+
+// WriteContext is like Write but checks ctx between characters. If ctx is
+// done part way it returns the number of bytes written and ctx.Err().
+//
+// Bytes that reach the end of the line are handled as Opts.Overflow says.
+// Truncated bytes count as written.
+func (d *Dev) WriteContext(ctx context.Context, buf []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	policy := d.overflow()
+	line, col, visible := d.writePosition()
+	for i := range buf {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if policy != OverflowRaw && (!visible || col >= d.opts.Cols) {
+			if policy == OverflowTruncate {
+				return len(buf), nil
+			}
+			if !visible || line >= d.opts.Lines {
+				return i, io.ErrShortWrite
+			}
+			line, col = line+1, 0
+			if err := d.setPosition(line, col); err != nil {
+				return i, err
+			}
+		}
+		if _, err := d.writeData(buf[i : i+1]); err != nil {
+			return i, err
+		}
+		col++
+	}
+	return len(buf), nil
+}
+
+// overflow returns the policy for text running off a line. Outside the
+// default entry mode the driver cannot tell where a line ends, so it is
+// always OverflowRaw there.
+func (d *Dev) overflow() OverflowPolicy {
+	if d.shiftRight || d.displayShift {
+		return OverflowRaw
+	}
+	return d.opts.Overflow
+}
+
+// WriteChar writes a single character at the cursor.
+//
+// Afterwards the controller moves the cursor one cell in the entry mode
+// direction: right by default (OPT_Increment set), left once
+// SetShiftRight(true) clears OPT_Increment. With SetDisplayShift(true)
+// (OPT_Cursor_Shift) the whole display moves the other way on each write, so
+// the cursor appears to stay put. CursorShift moves the cursor one cell
+// without writing, on top of whatever the entry mode did; to write without
+// advancing, move back with SetDDRAMAddress. A bus error is returned.
+func (d *Dev) WriteChar(char byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.writeData([]byte{char})
+	return err
+}
+
+// SendCommand sends b to the controller's instruction register and waits
+// its execution time, for opcodes the driver does not wrap.
+//
+// It bypasses the driver's cached state: a display control byte sent this
+// way does not change what DisplayOn reports, and an address or shift
+// command is not followed by Position or the positioned writes.
+func (d *Dev) SendCommand(b byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.command(b)
+}
+
+// SendData sends b to the controller's data register at the address counter
+// and waits for it as a character write does. Like SendCommand it bypasses
+// the driver's cached state, so Position and Snapshot do not see it.
+func (d *Dev) SendData(b byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.write(b, false); err != nil {
+		return err
+	}
+	return d.wait(b)
+}
+
+// RawOp is one step of a WriteSequence: Value goes to the instruction
+// register when Command is set, otherwise to the data register, and Delay
+// is the wait afterwards.
+type RawOp struct {
+	Value   byte
+	Command bool
+	Delay   time.Duration
+}
+
+// WriteSequence replays seq verbatim, such as a vendor init blob for a
+// clone the driver does not model. Each op waits its Delay afterwards, or
+// when that is zero the usual execution time, as SendCommand and SendData
+// do. Like them it bypasses the driver's cached state. It stops at the first
+// bus error, which names the failing op's index.
+func (d *Dev) WriteSequence(seq []RawOp) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, op := range seq {
+		if err := d.write(op.Value, op.Command); err != nil {
+			return fmt.Errorf("lcd1602: sequence op %d: %w", i, err)
+		}
+		switch {
+		case op.Delay > 0:
+			d.opts.clock().Sleep(op.Delay)
+		case op.Command:
+			d.opts.clock().Sleep(d.delayFor(op.Value, false))
+		default:
+			if err := d.wait(op.Value); err != nil {
+				return fmt.Errorf("lcd1602: sequence op %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Position returns the line (1 based) and column (0 based) of the cursor.
+//
+// The position is tracked in software from every write, so it follows the
+// entry mode direction. Once the cursor runs past the visible columns the
+// column keeps counting through the off-screen part of the DDRAM line.
+func (d *Dev) Position() (line, col byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if line, col, ok := d.position(); ok {
+		return line, col
+	}
+	line = 1 + 2*d.ctrl
+	if d.addr >= 0x40 {
+		return line + 1, d.addr - 0x40
+	}
+	return line, d.addr
+}
+
+// BacklightOn reports whether the backlight was last switched on.
+func (d *Dev) BacklightOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.backlight_state
+}
+
+// DisplayOn reports whether the display is enabled.
+func (d *Dev) DisplayOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.displayEnable
+}
+
+// CursorOn reports whether the underline cursor is shown.
+func (d *Dev) CursorOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cursor
+}
+
+// BlinkOn reports whether the cursor cell blinks.
+func (d *Dev) BlinkOn() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.blink
+}
+
+// Dimensions returns the number of lines and columns the display was
+// configured with, or last set with SetLines.
+func (d *Dev) Dimensions() (lines, cols byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.opts.Lines, d.opts.Cols
+}
+
+// SetLines switches the controller between 1-line and 2-line mode at run
+// time for a display with n lines, sending Function Set again, and clears
+// the screen, since the DDRAM layout changes with the mode. The new geometry
+// must pass the same checks as Opts; a Font5x10 Opts.Font rules out n > 1.
+func (d *Dev) SetLines(n uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	opts := d.opts
+	opts.Lines = n
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	d.opts.Lines = n
+	d.shown = newFrame(n, d.opts.Cols)
+	if d.batch != nil {
+		d.batch = newFrame(n, d.opts.Cols)
+	}
+	if err := d.functionSet(); err != nil {
+		return err
+	}
+	return d.clear()
+}
+
+// Right returns the number of columns.
+//
+// Deprecated: use Dimensions.
+func (d *Dev) Right() byte {
+	return d.opts.Cols
+}
+
+func (d *Dev) setBacklight(on bool) error {
+	if err := d.writePort(d.backlightBit(0x00, on)); err != nil {
+		return err
+	}
+	d.backlight_state = on
+	return nil
+}
+
+func (d *Dev) clear() error {
+	if err := d.touch(); err != nil {
+		return err
+	}
+	if d.batch != nil {
+		if err := d.checkOpen(); err != nil {
+			return err
+		}
+		blank(d.batch)
+		d.addr, d.filled = 0, lineEnd{}
+		return d.homeRotated()
+	}
+	if err := d.both(func() error { return d.command(CMD_Clear_Display) }); err != nil {
+		return err
+	}
+	if err := d.selectController(0); err != nil {
+		return err
+	}
+	blank(d.shown)
+	d.addr, d.shift, d.filled = 0, 0, lineEnd{}
+	for slot, o := range d.cgram {
+		if o == cgramScratch {
+			d.cgram[slot] = cgramFree
+		}
+	}
+	// Clear sets the controller back to incrementing, and on some clones
+	// drops the display shift bit too, so put the cached entry mode back.
+	// The display control bits are left alone by every part seen so far.
+	if err := d.writeEntryMode(); err != nil {
+		return err
+	}
+	return d.homeRotated()
+}
+
+func (d *Dev) home() error {
+	if d.batch != nil {
+		if err := d.checkOpen(); err != nil {
+			return err
+		}
+		d.addr, d.filled = 0, lineEnd{}
+		return d.homeRotated()
+	}
+	if err := d.both(func() error { return d.command(CMD_Return_Home) }); err != nil {
+		return err
+	}
+	if err := d.selectController(0); err != nil {
+		return err
+	}
+	d.addr, d.shift, d.filled = 0, 0, lineEnd{}
+	return d.homeRotated()
+}
+
+// homeRotated moves the cursor to the top left as the reader sees it,
+// which on a rotated panel is the controller's last cell rather than
+// address 0.
+func (d *Dev) homeRotated() error {
+	if !d.opts.Rotate180 {
+		return nil
+	}
+	return d.setPosition(1, 0)
+}
+
+func (d *Dev) setPosition(line, pos byte) error {
+	if err := d.touch(); err != nil {
+		return err
+	}
+	if line < 1 || line > d.opts.Lines {
+		return fmt.Errorf("lcd1602 %x: %w: %d, want 1-%d", d.opts.I2CAddr, ErrLineOutOfRange, line, d.opts.Lines)
+	}
+	if pos >= d.opts.Cols {
+		return fmt.Errorf("lcd1602 %x: %w: %d, want 0-%d", d.opts.I2CAddr, ErrColOutOfRange, pos, d.opts.Cols-1)
+	}
+	if pl, pc := d.physical(line, pos); d.lineOffset(pl)&0x3F+pc >= ddramLineLen {
+		return fmt.Errorf("lcd1602 %x: %w: line %d col %d runs past the DDRAM line end at %#x", d.opts.I2CAddr, ErrColOutOfRange, line, pos, d.lineOffset(pl)&0x40+ddramLineLen-1)
 	}
-	if pos > d.opts.Cols {
-		return fmt.Errorf("lcd1602 %x: device does not support %d cols", line, d.opts.I2CAddr)
+	pl, _ := d.physical(line, pos)
+	if err := d.selectController(d.controllerOf(pl)); err != nil {
+		return err
+	}
+	return d.setDDRAMAddress(d.address(line, pos))
+}
+
+// SetDDRAMAddress points the address counter straight at DDRAM address
+// addr, for layouts SetPosition does not describe. addr must be a real
+// DDRAM cell: 0x00-0x27 or 0x40-0x67. Position and the positioned writes
+// follow the move.
+func (d *Dev) SetDDRAMAddress(addr byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setDDRAMAddress(addr)
+}
+
+func (d *Dev) setDDRAMAddress(addr byte) error {
+	if addr > 0x67 || addr&0x3F >= ddramLineLen {
+		return fmt.Errorf("lcd1602 %x: DDRAM address %#x outside 0x00-0x27 and 0x40-0x67", d.opts.I2CAddr, addr)
+	}
+	if d.batch != nil {
+		if err := d.checkOpen(); err != nil {
+			return err
+		}
+		d.addr, d.filled = addr, lineEnd{}
+		return nil
+	}
+	if err := d.command(CMD_DDRAM_Set + addr); err != nil {
+		return err
+	}
+	d.addr, d.filled = addr, lineEnd{}
+	return nil
+}
+
+// lineOffset returns the DDRAM address of the first column of line, from
+// Opts.LineOffsets when set.
+//
+// By default lines 3 and 4 continue lines 1 and 2 past the last visible
+// column, so they start at 0x10/0x50 on 16 column parts and 0x14/0x54 on 20
+// column parts.
+func (d *Dev) lineOffset(line byte) byte {
+	if d.opts.LineOffsets != ([4]byte{}) && line >= 1 && line <= 4 {
+		return d.opts.LineOffsets[line-1]
+	}
+	if d.dual() && line > 2 {
+		// Lines 3 and 4 are lines 1 and 2 of the second controller.
+		line -= 2
 	}
-	var address byte
 	switch line {
-	case 1:
-		address = pos
 	case 2:
-		address = 0x40 + pos
+		return 0x40
 	case 3:
-		address = 0x10 + pos
+		return d.opts.Cols
 	case 4:
-		address = 0x50 + pos
+		return 0x40 + d.opts.Cols
+	}
+	return 0x00
+}
+
+// ddramLineLen is the length of each DDRAM line in 2-line mode. The display
+// shift wraps around it.
+const ddramLineLen = 40
+
+// address returns the DDRAM address shown at col on line, allowing for the
+// display shift.
+func (d *Dev) address(line, col byte) byte {
+	line, col = d.physical(line, col)
+	start := d.lineOffset(line)
+	return start&0x40 + (start&0x3F+col+d.shift)%ddramLineLen
+}
+
+// position maps the tracked address counter back to a visible line and
+// column, allowing for the display shift. ok is false when the counter sits
+// outside the visible area.
+func (d *Dev) position() (line, col byte, ok bool) {
+	return d.positionOf(d.addr)
+}
+
+// positionOf maps DDRAM address addr to a visible line and column.
+func (d *Dev) positionOf(addr byte) (line, col byte, ok bool) {
+	row, cell := addr&0x40, addr&0x3F
+	if cell >= ddramLineLen {
+		return 0, 0, false
 	}
-	d.command(CMD_DDRAM_Set + address)
+	cell = (cell + ddramLineLen - d.shift) % ddramLineLen
+	for line = 1; line <= d.opts.Lines; line++ {
+		start := d.lineOffset(line)
+		if d.controllerOf(line) == d.ctrl && start&0x40 == row && cell >= start&0x3F && cell < start&0x3F+d.opts.Cols {
+			line, col = d.physical(line, cell-start&0x3F)
+			return line, col, true
+		}
+	}
+	return 0, 0, false
+}
+
+// lineEnd is a line whose last cell a data write just filled, with the
+// address counter and controller that left behind.
+type lineEnd struct {
+	line, addr, ctrl byte
+}
+
+// writePosition is position for the write paths. After a write that exactly
+// fills a line it reports that line with col at Cols: the counter has run on
+// to the next DDRAM cell, which position may place at the start of some other
+// line, as 0x14 is line 3 on a 20x4.
+func (d *Dev) writePosition() (line, col byte, ok bool) {
+	if f := d.filled; f.line != 0 && f.addr == d.addr && f.ctrl == d.ctrl {
+		return f.line, d.opts.Cols, true
+	}
+	return d.position()
+}
+
+// physical maps a line and column as the caller sees them to the panel's
+// own, which differ when Opts.Rotate180 is set. The mapping is its own
+// inverse.
+func (d *Dev) physical(line, col byte) (byte, byte) {
+	if !d.opts.Rotate180 {
+		return line, col
+	}
+	return d.opts.Lines + 1 - line, d.opts.Cols - 1 - col
+}
+
+// forward reports whether the address counter increments after a write,
+// which is the other way to the text when the panel is rotated.
+func (d *Dev) forward() bool {
+	return !d.shiftRight != d.opts.Rotate180
+}
+
+// VisibleOffset returns how many cells the display shift has moved the
+// visible window along DDRAM, 0-39. Clear and Home reset it to 0.
+//
+// SetPosition and the other positioned writes already allow for the shift,
+// so column 0 is always the leftmost visible cell. The driver's copy of the
+// screen does not move with a shift, though: after DisplayShift, Snapshot
+// and diffed writes such as Flush are only right again once the screen has
+// been redrawn.
+func (d *Dev) VisibleOffset() byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.shift
+}
+
+// WithCursorPreserved runs fn and then puts the cursor back where it was
+// before, for helpers that draw somewhere else on the screen. fn runs
+// without the driver's lock, so it may call any method of d.
+//
+// The cursor is taken from the driver's tracked address, so raw moves made
+// with SendCommand are not undone; call ReadAddress first to pick those up.
+// The cursor is put back even when fn fails, and fn's error wins.
+func (d *Dev) WithCursorPreserved(fn func() error) error {
+	d.mu.Lock()
+	cur := d.saveCursor()
+	d.mu.Unlock()
+	err := fn()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if restoreErr := d.restoreCursor(cur); err == nil {
+		err = restoreErr
+	}
+	return err
+}
+
+// cursorPos is a cursor saved by saveCursor.
+type cursorPos struct {
+	addr, ctrl byte
+	filled     lineEnd
+}
+
+// saveCursor returns the cursor for restoreCursor.
+func (d *Dev) saveCursor() cursorPos {
+	return cursorPos{d.addr, d.ctrl, d.filled}
+}
+
+// restoreCursor points the address counter back at a saved cursor. In
+// batch mode only the tracked address changes.
+func (d *Dev) restoreCursor(cur cursorPos) error {
+	if err := d.selectController(cur.ctrl); err != nil {
+		return err
+	}
+	if d.batch == nil {
+		if err := d.command(CMD_DDRAM_Set | cur.addr&0x7F); err != nil {
+			return err
+		}
+	}
+	d.addr, d.filled = cur.addr, cur.filled
 	return nil
 }
 
-func (d *Dev) Write(buf []byte) (int, error) {
-	for _, c := range buf {
-		d.write(c, false)
-		time.Sleep(d.opts.CharDelay)
+// dual reports whether the display has a second controller on
+// Opts.Enable2Pin.
+func (d *Dev) dual() bool {
+	return d.opts.Enable2Pin != 0
+}
+
+// controllerOf returns the controller that drives physical line.
+func (d *Dev) controllerOf(line byte) byte {
+	if d.dual() && line > 2 {
+		return 1
 	}
-	return len(buf), nil
+	return 0
 }
 
-func (d *Dev) WriteChar(char byte) error {
-	d.write(char, false)
-	d.CursorShift(false)
+// selectController makes ctrl the one the cursor is on. Only the active
+// controller shows the cursor, so the display switch is sent again when it
+// is visible.
+func (d *Dev) selectController(ctrl byte) error {
+	if ctrl == d.ctrl {
+		return nil
+	}
+	d.ctrl = ctrl
+	if d.batch == nil && (d.cursor || d.blink) {
+		return d.writeDisplaySwitch()
+	}
 	return nil
 }
 
-func (d *Dev) Right() byte {
-	return d.opts.Cols
+// both runs fn with every write going to both controllers at once; it is
+// the same as calling fn on single controller displays. The shared data
+// lines let the two latch the same bytes together.
+func (d *Dev) both(fn func() error) error {
+	all := d.all
+	d.all = true
+	defer func() { d.all = all }()
+	return fn()
+}
+
+// enBits returns the enable pins to pulse for the controller being
+// written.
+func (d *Dev) enBits() byte {
+	switch {
+	case !d.dual():
+		return 1 << d.pins.EN
+	case d.all:
+		return 1<<d.pins.EN | 1<<d.opts.Enable2Pin
+	case d.ctrl == 1:
+		return 1 << d.opts.Enable2Pin
+	}
+	return 1 << d.pins.EN
+}
+
+// writeData sends buf as character data, following the address counter and
+// pacing each byte with wait.
+func (d *Dev) writeData(buf []byte) (int, error) {
+	if err := d.touch(); err != nil {
+		return 0, err
+	}
+	if d.batch != nil {
+		// Batched writes only reach write at Flush, so check here too.
+		if err := d.checkOpen(); err != nil {
+			return 0, err
+		}
+	}
+	for i, c := range buf {
+		if d.batch != nil {
+			d.store(d.batch, c)
+			d.advanceData()
+			continue
+		}
+		if err := d.write(c, false); err != nil {
+			return i, err
+		}
+		d.releaseScratch(d.addr, c)
+		d.store(d.shown, c)
+		d.advanceData()
+		if d.displayShift {
+			d.shiftWindow(d.shiftRight)
+		}
+		if err := d.wait(c); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(buf), nil
+}
+
+// advanceData is advance after a data write, noting in d.filled when the
+// write ran off the end of a line.
+func (d *Dev) advanceData() {
+	line, col, ok := d.position()
+	d.advance()
+	d.filled = lineEnd{}
+	if ok && col == d.opts.Cols-1 && !d.shiftRight && !d.displayShift {
+		d.filled = lineEnd{line, d.addr, d.ctrl}
+	}
 }
 
-func makeDev(c conn.Conn, isSPI bool, opts *Opts) (*Dev, error) {
+// makeDev initialises a display wired to p.
+func makeDev(ctx context.Context, p port, isSPI bool, opts *Opts) (*Dev, error) {
 	d := &Dev{
 		displayEnable: true,
-		cursor:        true,
-		blink:         true,
+		cursor:        opts.ShowCursor,
+		blink:         opts.Blink,
 		displayShift:  false,
 		shiftRight:    false,
 		opts:          *opts,
 		isSPI:         isSPI,
-		c:             mmr.Dev8{Conn: c, Order: binary.LittleEndian},
-	}
-
-	// Activate LCD
-	var data byte
-	data = pinInterpret(D4, data, true)
-	data = pinInterpret(D5, data, true)
-	d.enable(data)
-	time.Sleep(200 * time.Millisecond)
-	d.enable(data)
-	time.Sleep(100 * time.Millisecond)
-	d.enable(data)
-	time.Sleep(100 * time.Millisecond)
-
-	// Initialize 4-bit mode
-	data = pinInterpret(D4, data, false)
-	d.enable(data)
-	time.Sleep(10 * time.Millisecond)
-
-	d.command(CMD_Function_Set | OPT_2_Lines)
-	// d.command(CMD_Display_Control | OPT_Enable_Display)
-	d.writeDisplaySwitch()
-	d.writeEntryMode()
-	d.command(CMD_Clear_Display)
+		p:             p,
+		shown:         newFrame(opts.Lines, opts.Cols),
+		pins:          opts.pinMap(),
+	}
+	if opts.CaptureBytes > 0 {
+		d.ring = &captureRing{buf: make([]Transaction, opts.CaptureBytes)}
+	}
+
+	if opts.SkipInit {
+		// Trust that the controller is already in 4-bit mode and only bring
+		// it in line with our cached settings.
+		if err := d.configure(); err != nil {
+			return nil, err
+		}
+		if err := d.command(CMD_DDRAM_Set); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if err := d.reset(ctx); err != nil {
+		return nil, err
+	}
 	return d, nil
 }
 
-func (d *Dev) SetDisplayShift(value bool) {
+// Reset replays the power-on initialisation, putting the controller back in
+// 4-bit mode and reapplying the current display, cursor, blink and entry mode
+// settings. The screen is cleared.
+//
+// Use it to recover a display that shows garbage after a glitch without
+// recreating the Dev.
+func (d *Dev) Reset() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reset(context.Background())
+}
+
+func (d *Dev) reset(ctx context.Context) error {
+	// Initialisation by instruction, datasheet figure 24: whatever mode the
+	// controller is in, three 0x3 nibbles put it in 8-bit mode, then 0x2
+	// switches it to 4-bit mode. Wait out power-on first.
+	if err := d.hold(ctx, 50*time.Millisecond); err != nil {
+		return err
+	}
+	steps := []struct {
+		nibble byte
+		hold   time.Duration
+	}{
+		{0x03, 5 * time.Millisecond}, // > 4.1ms
+		{0x03, 200 * time.Microsecond},
+		{0x03, 200 * time.Microsecond}, // > 100µs
+		// Initialize 4-bit mode
+		{0x02, 200 * time.Microsecond},
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.both(func() error { return d.enable(d.enableBase(d.nibble(step.nibble, true), d.backlight_state)) }); err != nil {
+			return err
+		}
+		if err := d.hold(ctx, step.hold); err != nil {
+			return err
+		}
+	}
+
+	if err := d.configure(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.clear()
+}
+
+// configure sends function set, display control and entry mode from the
+// cached state. The controller must already be in 4-bit mode.
+func (d *Dev) configure() error {
+	if err := d.functionSet(); err != nil {
+		return err
+	}
+	// d.command(CMD_Display_Control | OPT_Enable_Display)
+	if err := d.writeDisplaySwitch(); err != nil {
+		return err
+	}
+	return d.writeEntryMode()
+}
+
+// functionSet sends Function Set for the configured lines and font. 2-line
+// mode is used for more than one line, and for a line at 0x40, as on the
+// 16x1 modules wired as two halves; see Opts16x1Split.
+func (d *Dev) functionSet() error {
+	function := byte(CMD_Function_Set)
+	if d.opts.Lines > 1 || d.lineOffset(1)&0x40 != 0 {
+		function |= OPT_2_Lines
+	}
+	if d.opts.Font == Font5x10 {
+		// The controller only drives the taller font in 1-line mode.
+		function |= OPT_5x10_Dots
+	}
+	return d.both(func() error { return d.command(function) })
+}
+
+// hold pauses for t on Opts.Clock, returning early with ctx.Err() if ctx
+// is done first. A custom clock cannot be interrupted, so ctx is only
+// checked around its Sleep.
+func (d *Dev) hold(ctx context.Context, t time.Duration) error {
+	if d.opts.Clock == nil {
+		return sleep(ctx, t)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.opts.Clock.Sleep(t)
+	return ctx.Err()
+}
+
+// sleep pauses for t, returning early with ctx.Err() if ctx is done first.
+func sleep(ctx context.Context, t time.Duration) error {
+	timer := time.NewTimer(t)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetDisplayShift sets whether the whole display shifts on each character
+// written (OPT_Cursor_Shift), see WriteChar.
+func (d *Dev) SetDisplayShift(value bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.displayShift = value
-	d.writeEntryMode()
+	return d.writeEntryMode()
 }
 
-func (d *Dev) SetShiftRight(value bool) {
+// SetShiftRight(true) makes the cursor move left after each character, which
+// suits right to left text. Despite the name nothing moves right: it clears
+// OPT_Increment. SetEntryMode takes the same setting the right way round, as
+// increment.
+func (d *Dev) SetShiftRight(value bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.shiftRight = value
-	d.writeEntryMode()
+	return d.writeEntryMode()
+}
+
+// SetEntryMode sets the whole entry mode in one command: with increment the
+// cursor moves right after each character (OPT_Increment), otherwise left;
+// with shiftDisplay the display shifts on each character as well
+// (OPT_Cursor_Shift).
+func (d *Dev) SetEntryMode(increment, shiftDisplay bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shiftRight, d.displayShift = !increment, shiftDisplay
+	return d.writeEntryMode()
+}
+
+// SetIncrement sets only the direction the cursor moves after each
+// character, right with increment and left without, leaving the display
+// shift setting alone. Decrement without display shift writes right to left
+// text.
+func (d *Dev) SetIncrement(increment bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shiftRight = !increment
+	return d.writeEntryMode()
+}
+
+// Increment reports whether the cursor moves right after each character.
+func (d *Dev) Increment() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.shiftRight
+}
+
+// EntryMode returns the entry mode as SetEntryMode takes it.
+func (d *Dev) EntryMode() (increment, shiftDisplay bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.shiftRight, d.displayShift
+}
+
+// CursorMode combines the underline cursor and cell blink settings.
+type CursorMode uint8
+
+const (
+	CursorOff            CursorMode = iota // no cursor
+	CursorUnderline                        // steady underline
+	CursorBlink                            // blinking block
+	CursorUnderlineBlink                   // underline over a blinking block
+)
+
+// SetCursorMode sets how the cursor is shown with a single display control
+// command. Prefer it to SetCursor and SetBlink.
+func (d *Dev) SetCursorMode(m CursorMode) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cursor = m == CursorUnderline || m == CursorUnderlineBlink
+	d.blink = m == CursorBlink || m == CursorUnderlineBlink
+	return d.writeDisplaySwitch()
 }
 
-func (d *Dev) writeDisplaySwitch() {
+// SetDisplay turns the display on or off. DDRAM contents are kept while off.
+func (d *Dev) SetDisplay(on bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.displayEnable = on
+	return d.writeDisplaySwitch()
+}
+
+// SetCursor shows or hides the underline cursor.
+func (d *Dev) SetCursor(on bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cursor = on
+	return d.writeDisplaySwitch()
+}
+
+// SetBlink turns blinking of the cursor cell on or off.
+func (d *Dev) SetBlink(on bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.blink = on
+	return d.writeDisplaySwitch()
+}
+
+func (d *Dev) writeDisplaySwitch() error {
 	option := byte(CMD_Display_Control)
 	if d.displayEnable {
 		option = option | OPT_Enable_Display
@@ -202,91 +1218,321 @@ func (d *Dev) writeDisplaySwitch() {
 	if d.blink {
 		option = option | OPT_Enable_Blink
 	}
-	log.Info("Writing display switch")
-	d.command(option)
+	d.debug("display switch", "option", option)
+	if d.dual() {
+		// The controller the cursor is not on keeps its cursor off, or
+		// there would be two.
+		d.ctrl ^= 1
+		err := d.command(option &^ (OPT_Enable_Cursor | OPT_Enable_Blink))
+		d.ctrl ^= 1
+		if err != nil {
+			return err
+		}
+	}
+	return d.command(option)
 }
 
-func (d *Dev) DisplayShift(right bool) {
+func (d *Dev) DisplayShift(right bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.shiftDisplay(right)
+}
+
+func (d *Dev) shiftDisplay(right bool) error {
 	option := byte(CMD_Cursor_Display_Shift | OPT_Display_Shift)
 	if right {
 		option = option | OPT_Shift_Right
 	}
-	d.command(option)
+	if err := d.both(func() error { return d.command(option) }); err != nil {
+		return err
+	}
+	d.shiftWindow(right)
+	return nil
+}
+
+// shiftWindow follows a display shift. Shifting the display right moves the
+// visible window towards lower addresses.
+func (d *Dev) shiftWindow(right bool) {
+	if right {
+		d.shift = (d.shift + ddramLineLen - 1) % ddramLineLen
+	} else {
+		d.shift = (d.shift + 1) % ddramLineLen
+	}
 }
 
-func (d *Dev) CursorShift(right bool) {
-	log.Info("Writing cursor shift")
+func (d *Dev) CursorShift(right bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cursorShift(right)
+}
+
+// MoveCursor moves the cursor steps cells, right for positive steps and
+// left for negative ones, with one cursor shift command per cell. Nothing is
+// written, the display does not move and the entry mode is left alone.
+//
+// Entry mode is what moves the cursor, and with SetDisplayShift the display,
+// automatically after each character written; MoveCursor is an explicit move
+// on top of that, like CursorShift repeated. The cursor wraps through
+// off-screen DDRAM as the controller's address counter does.
+func (d *Dev) MoveCursor(steps int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	right := steps > 0
+	if steps < 0 {
+		steps = -steps
+	}
+	for ; steps > 0; steps-- {
+		if err := d.cursorShift(right); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dev) cursorShift(right bool) error {
+	right = right != d.opts.Rotate180
 	option := byte(CMD_Cursor_Display_Shift)
 	if right {
 		option = option | OPT_Shift_Right
 	}
-	d.command(option)
+	if err := d.command(option); err != nil {
+		return err
+	}
+	d.step(right)
+	return nil
 }
 
-func (d *Dev) writeEntryMode() {
+func (d *Dev) writeEntryMode() error {
 	option := byte(CMD_Entry_Mode)
-	if !d.shiftRight {
+	if d.forward() {
 		option = option | OPT_Increment
 	}
 	if d.displayShift {
 		option = option | OPT_Cursor_Shift
 	}
-	d.command(option)
+	return d.both(func() error { return d.command(option) })
+}
+
+// inTextOrder runs fn with the entry mode set to increment without display
+// shift, as the runs of text written by the positioned writes assume, and
+// then puts the caller's entry mode back.
+func (d *Dev) inTextOrder(fn func() error) error {
+	shiftRight, displayShift := d.shiftRight, d.displayShift
+	if !shiftRight && !displayShift {
+		return fn()
+	}
+	d.shiftRight, d.displayShift = false, false
+	err := d.writeEntryMode()
+	if err == nil {
+		err = fn()
+	}
+	d.shiftRight, d.displayShift = shiftRight, displayShift
+	if restoreErr := d.writeEntryMode(); err == nil {
+		err = restoreErr
+	}
+	return err
 }
 
-func (d *Dev) command(data byte) {
-	d.write(data, true)
+// advance follows the controller's address counter after a data write,
+// which moves it in the entry mode direction.
+func (d *Dev) advance() {
+	d.step(d.forward())
 }
-func (d *Dev) WriteCell(char byte) {
-	d.write(0x40|char, false)
+
+// step moves the tracked address counter one cell the way the controller
+// does in 2-line mode: 0x00-0x27 and 0x40-0x67 run into each other.
+func (d *Dev) step(forward bool) {
+	switch {
+	case forward && d.addr == 0x27:
+		d.addr = 0x40
+	case forward && d.addr >= 0x67:
+		d.addr = 0x00
+	case forward:
+		d.addr++
+	case d.addr == 0x40:
+		d.addr = 0x27
+	case d.addr == 0x00:
+		d.addr = 0x67
+	default:
+		d.addr--
+	}
+}
+
+// execTime is the datasheet execution time of every instruction and data
+// write except Clear and Home.
+const execTime = 37 * time.Microsecond
+
+// delayFor returns how long the controller stays busy after opcode. Clear
+// and Home take up to 1.52ms, covered by Opts.ClearDelay and Opts.HomeDelay
+// (2ms by default); everything else takes execTime.
+func (d *Dev) delayFor(opcode byte, isData bool) time.Duration {
+	if !isData {
+		switch opcode {
+		case CMD_Clear_Display:
+			return d.opts.clearDelay()
+		case CMD_Return_Home, CMD_Return_Home | 1:
+			return d.opts.homeDelay()
+		}
+	}
+	return execTime
 }
-func (d *Dev) write(data byte, command bool) {
+
+// command sends an instruction byte and waits for it to execute.
+func (d *Dev) command(data byte) error {
+	if err := d.write(data, true); err != nil {
+		return err
+	}
+	d.opts.clock().Sleep(d.delayFor(data, false))
+	return nil
+}
+
+// write sends data as two nibbles and returns the first bus error hit. The
+// backlight bit is read once, so both nibbles carry the same one.
+func (d *Dev) write(data byte, command bool) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	d.debug("write", "data", data, "command", command)
+	light := d.backlight_state
+	if b, ok := d.p.(portBurster); ok && d.opts.FastWrite {
+		return d.writeBurst(b, data, command, light)
+	}
+	//  Toggle Enable for the high nibble, then the low one
+	if err := d.enable(d.enableBase(d.nibble(data>>4, command), light)); err != nil {
+		return err
+	}
+	return d.enable(d.enableBase(d.nibble(data&0x0F, command), light))
+}
+
+// nibble returns the port byte that puts the low four bits of n on D4-D7,
+// with the register selector set to 1 if this is data.
+func (d *Dev) nibble(n byte, command bool) byte {
 	var i2c_data byte
-	log.Infof("Writing %b %x", data, data)
-	// Add data for high nibble
-	hi_nibble := data >> 4
-	i2c_data = pinInterpret(D4, i2c_data, (hi_nibble&0x01 == 0x01))
-	i2c_data = pinInterpret(D5, i2c_data, ((hi_nibble>>1)&0x01 == 0x01))
-	i2c_data = pinInterpret(D6, i2c_data, ((hi_nibble>>2)&0x01 == 0x01))
-	i2c_data = pinInterpret(D7, i2c_data, ((hi_nibble>>3)&0x01 == 0x01))
-
-	// # Set the register selector to 1 if this is data
+	i2c_data = pinInterpret(d.pins.D4, i2c_data, (n&0x01 == 0x01))
+	i2c_data = pinInterpret(d.pins.D5, i2c_data, ((n>>1)&0x01 == 0x01))
+	i2c_data = pinInterpret(d.pins.D6, i2c_data, ((n>>2)&0x01 == 0x01))
+	i2c_data = pinInterpret(d.pins.D7, i2c_data, ((n>>3)&0x01 == 0x01))
 	if !command {
-		i2c_data = pinInterpret(RS, i2c_data, true)
+		i2c_data = pinInterpret(d.pins.RS, i2c_data, true)
+	}
+	return i2c_data
+}
+
+// writeBurst sends both nibbles of data with their EN pulses as a single
+// six byte transaction, where write otherwise makes six one byte ones. There
+// are no sleeps between the bytes: at 100kHz each one takes about 90µs on the
+// wire, which already covers the settle and pulse width times.
+func (d *Dev) writeBurst(b portBurster, data byte, command, light bool) error {
+	var seq [6]byte
+	for i, n := range []byte{data >> 4, data & 0x0F} {
+		base := d.enableBase(d.nibble(n, command), light)
+		seq[i*3] = base
+		seq[i*3+1] = base | d.enBits()
+		seq[i*3+2] = base
+	}
+	for _, c := range seq {
+		d.capture(c)
+	}
+	// A failed I2C transaction is normally refused at the address, before
+	// any byte reaches the expander, so the whole burst is safe to repeat.
+	err := d.retry(func() error { return b.writeBytes(seq[:]) })
+	if err != nil {
+		// As in enable, make sure EN does not stay high.
+		d.p.writeByte(seq[5])
 	}
+	return err
+}
 
-	//  Toggle Enable
-	d.enable(i2c_data)
+// backgroundError hands an error from a background goroutine to
+// Opts.OnError, if set. Callers must not hold d.mu.
+func (d *Dev) backgroundError(err error) {
+	if d.opts.OnError != nil {
+		d.opts.OnError(err)
+	}
+}
 
-	i2c_data = 0x00
+// enableBase adds the backlight bit for light and RW low to a port byte
+// about to be clocked in with EN.
+func (d *Dev) enableBase(data byte, light bool) byte {
+	// Insure the back light does not turn off or on
+	data = d.backlightBit(data, light)
+	// RW low: the controller only drives the data lines on reads.
+	return pinInterpret(d.pins.RW, data, false)
+}
 
-	// Add data for high nibble
-	low_nibble := data & 0x0F
-	i2c_data = pinInterpret(D4, i2c_data, (low_nibble&0x01 == 0x01))
-	i2c_data = pinInterpret(D5, i2c_data, ((low_nibble>>1)&0x01 == 0x01))
-	i2c_data = pinInterpret(D6, i2c_data, ((low_nibble>>2)&0x01 == 0x01))
-	i2c_data = pinInterpret(D7, i2c_data, ((low_nibble>>3)&0x01 == 0x01))
+// backlightBit sets the backlight pin in data for the light to be on or
+// off, honouring Opts.BacklightActiveLow.
+func (d *Dev) backlightBit(data byte, on bool) byte {
+	return pinInterpret(d.pins.Backlight, data, on != d.opts.BacklightActiveLow)
+}
 
-	// Set the register selector to 1 if this is data
-	if !command {
-		i2c_data = pinInterpret(RS, i2c_data, true)
+// debug logs a trace message to Opts.Logger, if one is set.
+func (d *Dev) debug(msg string, args ...any) {
+	if d.opts.Logger != nil {
+		d.opts.Logger.Debug(msg, args...)
 	}
+}
 
-	d.enable(i2c_data)
+// enable clocks data, as built by enableBase, into the controller with a
+// pulse on EN.
+//
+// If any of the three port writes fails, a last plain write of data with EN
+// low is attempted before the error is returned, so a write that failed
+// mid-pulse does not leave EN high. The controller then has at worst taken
+// a stray nibble, which Reset recovers from.
+func (d *Dev) enable(data byte) error {
+	err := d.writePort(data)
+	if err == nil {
+		d.opts.clock().Sleep(d.opts.enableSettleTime())
+		err = d.writePort(data | d.enBits())
+	}
+	if err == nil {
+		d.opts.clock().Sleep(d.opts.enablePulseWidth())
+		err = d.writePort(data)
+	}
+	if err != nil {
+		// Best effort; the original error is the one worth reporting.
+		d.p.writeByte(data)
+	}
+	return err
 }
 
-func (d *Dev) enable(data byte) {
-	// Determine if black light is on and insure it does not turn off or on
-	if d.backlight_state {
-		data = pinInterpret(BACKLIGHT, data, true)
-	} else {
-		data = pinInterpret(BACKLIGHT, data, false)
+// checkOpen fails once the Dev is closed.
+func (d *Dev) checkOpen() error {
+	if d.closed {
+		return fmt.Errorf("lcd1602: %w", ErrClosed)
+	}
+	return nil
+}
+
+// writePort latches b on the expander, retrying transient bus failures up
+// to Opts.WriteRetries times. The wait between attempts starts at
+// Opts.RetryBackoff and doubles each time.
+func (d *Dev) writePort(b byte) error {
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	d.capture(b)
+	return d.retry(func() error { return d.p.writeByte(b) })
+}
+
+// retry runs write, a single bus transaction, until it succeeds or has
+// been retried Opts.WriteRetries times, sleeping for the backoff between
+// attempts. The final failure is wrapped with ErrBusWrite.
+func (d *Dev) retry(write func() error) error {
+	backoff := d.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := write()
+		if err == nil {
+			return nil
+		}
+		if attempt >= d.opts.WriteRetries {
+			return fmt.Errorf("lcd1602: %w: %w", ErrBusWrite, err)
+		}
+		d.debug("retrying port write", "attempt", attempt+1, "err", err)
+		d.opts.clock().Sleep(backoff)
+		backoff *= 2
 	}
-	d.c.WriteUint8(0, data)
-	time.Sleep(40 * time.Microsecond)
-	d.c.WriteUint8(0, pinInterpret(EN, data, true))
-	time.Sleep(40 * time.Microsecond)
-	d.c.WriteUint8(0, data)
 }
 
 // Still don't completely understand this - hope to soon
@@ -0,0 +1,128 @@
+/*
+Copyright 2024 Tim St. Pierre
+Animation plumbing for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Animator is an effect that RunAnimation can drive, one frame at a time.
+// The built-in marquees, AutoShift and Spinner are Animators underneath.
+//
+// Only one animation should draw on a given part of the screen at a time;
+// two that overlap will overwrite each other's cells.
+type Animator interface {
+	// Frame draws frame n, counting from 0. It is called without the
+	// display's lock held, so it may call any method of the Dev.
+	Frame(n int) error
+	// Interval is the time between frames.
+	Interval() time.Duration
+	// Done reports whether the animation is over before frame n.
+	Done(n int) bool
+}
+
+// RunAnimation draws frame 0 of a straight away and then one frame every
+// a.Interval() until a is done, a frame fails or ctx is done. It returns
+// nil when a finishes, the frame's error, or ctx.Err(). An interval of zero
+// or less fails with an error wrapping ErrBadInterval before any frame.
+func RunAnimation(ctx context.Context, a Animator) error {
+	if err := checkInterval(a.Interval()); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(a.Interval())
+	defer ticker.Stop()
+	for n := 0; !a.Done(n); n++ {
+		if n > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.Frame(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// animation is an Animator made of a frame function.
+type animation struct {
+	interval time.Duration
+	frames   int // 0 runs until cancelled
+	frame    func(n int) error
+}
+
+func (a *animation) Frame(n int) error       { return a.frame(n) }
+func (a *animation) Interval() time.Duration { return a.interval }
+func (a *animation) Done(n int) bool         { return a.frames > 0 && n >= a.frames }
+
+// checkInterval fails for the intervals time.NewTicker would panic on.
+func checkInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("lcd1602: %w: %v", ErrBadInterval, interval)
+	}
+	return nil
+}
+
+// runBackground drives run in its own goroutine until the returned stop is
+// called, or Halt or Close. Errors other than the cancellation go to
+// Opts.OnError. stop waits for the goroutine to exit and may be called more
+// than once.
+func (d *Dev) runBackground(run func(ctx context.Context) error) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	stop = func() {
+		cancel()
+		<-done
+	}
+	untrack := d.track(stop)
+	go func() {
+		err := run(ctx)
+		// Let go of stop before reporting, so that OnError may itself call
+		// stop, Halt or Close.
+		untrack()
+		close(done)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			d.backgroundError(err)
+		}
+	}()
+	return stop
+}
+
+// track registers stop, which ends a background goroutine, for Halt and
+// Close. The returned untrack drops it again.
+func (d *Dev) track(stop func()) (untrack func()) {
+	d.bgMu.Lock()
+	defer d.bgMu.Unlock()
+	if d.bg == nil {
+		d.bg = make(map[int]func())
+	}
+	id := d.bgNext
+	d.bgNext++
+	d.bg[id] = stop
+	return func() {
+		d.bgMu.Lock()
+		defer d.bgMu.Unlock()
+		delete(d.bg, id)
+	}
+}
+
+// stopBackground stops every tracked goroutine and waits for them to exit.
+// Callers must not hold d.mu, which the goroutines may be waiting for.
+func (d *Dev) stopBackground() {
+	d.bgMu.Lock()
+	stops := d.bg
+	d.bg = nil
+	d.bgMu.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 animation plumbing
+*/
+package lcd1602
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor fails t if done is not closed soon.
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s did not return; deadlock?", what)
+	}
+}
+
+func TestOnErrorMayStop(t *testing.T) {
+	var d *Dev
+	var stop func()
+	done := make(chan struct{})
+	o := DefaultOpts
+	o.OnError = func(error) {
+		defer close(done)
+		stop()
+		if err := d.Halt(); err != nil {
+			t.Errorf("Halt from OnError: %v", err)
+		}
+	}
+	d, _, _ = newReadyDev(t, o)
+	// Fail only once stop is set.
+	start := make(chan struct{})
+	stop = d.runBackground(func(ctx context.Context) error {
+		<-start
+		return errors.New("boom")
+	})
+	close(start)
+	waitFor(t, done, "OnError calling stop and Halt")
+}
+
+func TestOnErrorMayStopScroll(t *testing.T) {
+	var s *ScrollController
+	done := make(chan struct{})
+	o := DefaultOpts
+	o.OnError = func(error) {
+		defer close(done)
+		s.Stop()
+	}
+	d, _, _ := newReadyDev(t, o)
+	// Line 3 is not on a 16x2, so the first frame fails.
+	s = NewScrollController(d, 3, "a line too long for the display")
+	s.Start()
+	waitFor(t, done, "OnError calling ScrollController.Stop")
+}
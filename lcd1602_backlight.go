@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Tim St. Pierre
+Backlight control for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"time"
+)
+
+const defaultPWMPeriod = 10 * time.Millisecond
+
+// SetBrightness sets the backlight level, 0 being off and 255 fully on.
+//
+// With Opts.PWMBacklight set, levels in between run a software PWM on the
+// backlight pin in a background goroutine. Each edge is an I²C transaction of
+// a few hundred microseconds at 100kHz and goroutine scheduling adds jitter,
+// so at the default 10ms period the usable resolution is roughly 20-30 steps
+// rather than 256. Without PWMBacklight any level above 0 turns the backlight
+// on. SetBacklight cancels a running PWM.
+func (d *Dev) SetBrightness(level uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopPWM()
+	if !d.opts.PWMBacklight || level == 0 || level == 255 {
+		return d.setBacklight(level > 0)
+	}
+	period := d.opts.PWMPeriod
+	if period <= 0 {
+		period = defaultPWMPeriod
+	}
+	on := period * time.Duration(level) / 255
+	stop := make(chan struct{})
+	d.pwmStop = stop
+	go d.pwm(stop, on, period-on)
+	return nil
+}
+
+// pwm toggles the backlight until stop is closed. stop is closed with d.mu
+// held, so checking it under the lock guarantees no edge is written after
+// stopPWM returns.
+func (d *Dev) pwm(stop chan struct{}, on, off time.Duration) {
+	for {
+		for _, phase := range []struct {
+			state bool
+			hold  time.Duration
+		}{{true, on}, {false, off}} {
+			d.mu.Lock()
+			select {
+			case <-stop:
+				d.mu.Unlock()
+				return
+			default:
+			}
+			err := d.setBacklight(phase.state)
+			d.mu.Unlock()
+			if err != nil {
+				d.backgroundError(err)
+				return
+			}
+			time.Sleep(phase.hold)
+		}
+	}
+}
+
+// stopPWM cancels a running backlight PWM. Callers must hold d.mu.
+func (d *Dev) stopPWM() {
+	if d.pwmStop != nil {
+		close(d.pwmStop)
+		d.pwmStop = nil
+	}
+}
+
+// FlashBacklight flips the backlight away from its current state and back
+// times times, holding each state for interval, to draw attention. The
+// backlight ends as it started, so a panel that was off ends off.
+//
+// Other calls may run between the flashes. A bus error stops the flashing
+// and is returned straight away.
+func (d *Dev) FlashBacklight(times int, interval time.Duration) error {
+	d.mu.Lock()
+	prior := d.backlight_state
+	d.mu.Unlock()
+	for i := 0; i < times; i++ {
+		for _, on := range []bool{!prior, prior} {
+			if err := d.SetBacklight(on); err != nil {
+				return err
+			}
+			d.opts.clock().Sleep(interval)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024 Tim St. Pierre
+Multiple displays driven as one surface
+*/
+package lcd1602
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BankRow maps one logical row of a Bank to a line of a member display.
+type BankRow struct {
+	Dev  int  // index into the Bank's displays
+	Line byte // line on that display, 1 based
+}
+
+// Bank treats several displays, typically on the same bus at different
+// addresses, as one taller surface. Logical rows are numbered from 1 like
+// display lines.
+//
+// Errors from a member are wrapped with the member's index and name.
+type Bank struct {
+	devs []*Dev
+	rows []BankRow
+	row  int  // index into rows of the cursor
+	col  byte // column of the cursor
+}
+
+// NewBank returns a Bank over devs. rows maps each logical row to a member
+// line; when nil, every line of devs[0] comes first, then devs[1] and so on.
+func NewBank(devs []*Dev, rows []BankRow) (*Bank, error) {
+	if len(devs) == 0 {
+		return nil, errors.New("lcd1602: bank needs at least one display")
+	}
+	if rows == nil {
+		for i, d := range devs {
+			lines, _ := d.Dimensions()
+			for line := byte(1); line <= lines; line++ {
+				rows = append(rows, BankRow{Dev: i, Line: line})
+			}
+		}
+	}
+	for i, r := range rows {
+		if r.Dev < 0 || r.Dev >= len(devs) {
+			return nil, fmt.Errorf("lcd1602: bank row %d refers to display %d of %d", i+1, r.Dev, len(devs))
+		}
+		if lines, _ := devs[r.Dev].Dimensions(); r.Line < 1 || r.Line > lines {
+			return nil, fmt.Errorf("lcd1602: bank row %d refers to line %d of %s", i+1, r.Line, devs[r.Dev])
+		}
+	}
+	return &Bank{devs: devs, rows: rows}, nil
+}
+
+// Clear clears every member and moves the cursor to row 1. All members are
+// cleared even if one fails; the failures are joined.
+func (b *Bank) Clear() error {
+	var errs []error
+	for i := range b.devs {
+		if err := b.devs[i].Clear(); err != nil {
+			errs = append(errs, b.memberErr(i, err))
+		}
+	}
+	b.row, b.col = 0, 0
+	return errors.Join(errs...)
+}
+
+// SetPosition moves the cursor to col (0 based) on logical row (1 based).
+func (b *Bank) SetPosition(row, col byte) error {
+	if row < 1 || int(row) > len(b.rows) {
+		return fmt.Errorf("lcd1602: bank row %d out of range 1-%d", row, len(b.rows))
+	}
+	r := b.rows[row-1]
+	if err := b.devs[r.Dev].SetPosition(r.Line, col); err != nil {
+		return b.memberErr(r.Dev, err)
+	}
+	b.row, b.col = int(row-1), col
+	return nil
+}
+
+// WriteString writes s at the cursor, wrapping onto the next logical row,
+// which may be on another member. '\n' moves to the start of the next row
+// and '\r' to the start of the current one. Like Dev.WriteString with
+// OverflowWrap it stops at the end of the last row with io.ErrShortWrite.
+func (b *Bank) WriteString(s string) (int, error) {
+	n := 0
+	for rest := []rune(s); len(rest) > 0; {
+		r := b.rows[b.row]
+		d := b.devs[r.Dev]
+		switch rest[0] {
+		case '\n':
+			if b.row+1 < len(b.rows) {
+				b.row, b.col = b.row+1, 0
+			} else {
+				b.col = d.opts.Cols
+			}
+			n, rest = n+1, rest[1:]
+			continue
+		case '\r':
+			b.col = 0
+			n, rest = n+1, rest[1:]
+			continue
+		}
+		if b.col >= d.opts.Cols {
+			if b.row+1 >= len(b.rows) {
+				return n, io.ErrShortWrite
+			}
+			b.row, b.col = b.row+1, 0
+			continue
+		}
+		chunk := rest
+		if room := int(d.opts.Cols - b.col); len(chunk) > room {
+			chunk = chunk[:room]
+		}
+		for i, c := range chunk {
+			if c == '\n' || c == '\r' {
+				chunk = chunk[:i]
+				break
+			}
+		}
+		// The member's own cursor may have been moved since, by another
+		// row mapped to it or by a Clear.
+		if err := d.SetPosition(r.Line, b.col); err != nil {
+			return n, b.memberErr(r.Dev, err)
+		}
+		if _, err := d.WriteString(string(chunk)); err != nil {
+			return n, b.memberErr(r.Dev, err)
+		}
+		n += len(string(chunk))
+		b.col += byte(len(chunk))
+		rest = rest[len(chunk):]
+	}
+	return n, nil
+}
+
+func (b *Bank) memberErr(i int, err error) error {
+	return fmt.Errorf("lcd1602: bank display %d (%s): %w", i, b.devs[i], err)
+}
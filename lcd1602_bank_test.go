@@ -0,0 +1,41 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 display banks
+*/
+package lcd1602
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBankWriteString(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	// Logical row 1 is the display's line 2, and row 2 its line 1.
+	b, err := NewBank([]*Dev{d}, []BankRow{{0, 2}, {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.WriteString("row1\nrow2"); n != 9 || err != nil {
+		t.Fatalf("WriteString = %d, %v, want 9, nil", n, err)
+	}
+	if got, want := d.Snapshot(), "row2            \nrow1            "; got != want {
+		t.Errorf("screen %q, want %q", got, want)
+	}
+	// Wrapping moves on to the next row as well.
+	if err := b.SetPosition(1, 12); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.WriteString("abcdefgh"); n != 8 || err != nil {
+		t.Fatalf("wrapping WriteString = %d, %v, want 8, nil", n, err)
+	}
+	if got, want := d.Snapshot(), "efgh            \nrow1        abcd"; got != want {
+		t.Errorf("screen %q, want %q", got, want)
+	}
+	if _, err := b.WriteString("\n\nx"); err != io.ErrShortWrite {
+		t.Errorf("WriteString past the last row = %v, want io.ErrShortWrite", err)
+	}
+}
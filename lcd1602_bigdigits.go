@@ -0,0 +1,96 @@
+/*
+Copyright 2024 Tim St. Pierre
+Large two-line digits for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// bigSegments are the block pieces the big digits are built from, loaded
+// into CGRAM slots 0-7 in order.
+var bigSegments = [CGRAMSlots][8]byte{
+	{0x07, 0x0F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F}, // upper left corner
+	{0x1F, 0x1F, 0x1F, 0x00, 0x00, 0x00, 0x00, 0x00}, // upper bar
+	{0x1C, 0x1E, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F}, // upper right corner
+	{0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x0F, 0x07}, // lower left corner
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x1F, 0x1F}, // lower bar
+	{0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1E, 0x1C}, // lower right corner
+	{0x1F, 0x1F, 0x1F, 0x00, 0x00, 0x00, 0x1F, 0x1F}, // upper and middle bar
+	{0x1F, 0x00, 0x00, 0x00, 0x00, 0x1F, 0x1F, 0x1F}, // middle and lower bar
+}
+
+// bigGlyphs holds the top and bottom rows of each digit, then the minus
+// sign. 0xFF is the ROM's full block.
+var bigGlyphs = [11][2][3]byte{
+	{{0, 1, 2}, {3, 4, 5}},
+	{{1, 2, ' '}, {4, 0xFF, 4}},
+	{{6, 6, 2}, {3, 7, 7}},
+	{{6, 6, 2}, {7, 7, 5}},
+	{{3, 4, 0xFF}, {' ', ' ', 0xFF}},
+	{{0xFF, 6, 6}, {7, 7, 5}},
+	{{0, 6, 6}, {3, 7, 5}},
+	{{1, 1, 2}, {' ', ' ', 0xFF}},
+	{{0, 6, 2}, {3, 7, 5}},
+	{{0, 6, 2}, {' ', ' ', 0xFF}},
+	{{4, 4, 4}, {' ', ' ', ' '}},
+}
+
+// BigDigits renders numbers three cells wide and two lines tall on lines 1
+// and 2, for clocks and timers.
+//
+// It takes over all eight CGRAM slots, so it fails if any are held, whether
+// by CreateChar and DefineChar or by another built-in glyph helper such as
+// ProgressBar. FreeChar the slots first to hand them over.
+type BigDigits struct {
+	d *Dev
+}
+
+// NewBigDigits returns a big digit renderer for d, which must have at least
+// two lines.
+func NewBigDigits(d *Dev) (*BigDigits, error) {
+	// SetLines may change the geometry at any time.
+	if lines, _ := d.Dimensions(); lines < 2 {
+		return nil, fmt.Errorf("lcd1602: big digits need 2 lines, display has %d", lines)
+	}
+	return &BigDigits{d: d}, nil
+}
+
+// WriteBigNumber draws n starting at startCol, with one blank column
+// between digits, and leaves the cursor where it was. It fails without
+// writing anything if n does not fit.
+func (b *BigDigits) WriteBigNumber(startCol byte, n int) error {
+	d := b.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	text := strconv.Itoa(n)
+	if width := len(text)*4 - 1; int(startCol)+width > int(d.opts.Cols) {
+		return fmt.Errorf("lcd1602: %d needs %d columns from col %d, display has %d", n, width, startCol, d.opts.Cols)
+	}
+	if err := d.reserve(cgramBig, 0, bigSegments[:]); err != nil {
+		return err
+	}
+	cur := d.saveCursor()
+	for row := 0; row < 2; row++ {
+		if err := d.setPosition(byte(row+1), startCol); err != nil {
+			return err
+		}
+		var cells []byte
+		for i, c := range text {
+			if i > 0 {
+				cells = append(cells, ' ')
+			}
+			glyph := bigGlyphs[10]
+			if c != '-' {
+				glyph = bigGlyphs[c-'0']
+			}
+			cells = append(cells, glyph[row][:]...)
+		}
+		if _, err := d.writeData(cells); err != nil {
+			return err
+		}
+	}
+	return d.restoreCursor(cur)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Tim St. Pierre
+Small bitmaps in custom glyphs for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+)
+
+// solidGlyph is a fully lit cell, which the A00 ROM has at 0xFF.
+var solidGlyph = [8]byte{0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F}
+
+// DrawBitmap draws a monochrome bitmap with its top left pixel in the cell
+// at col on line. bmp holds rows of pixels, top first, true being lit; rows
+// may differ in length. The bitmap is cut into 5x8 cells, so the panel's
+// gaps between cells split it up, and each distinct cell pattern is loaded
+// into a CGRAM slot. Blank cells, and full ones where the ROM has a solid
+// block, need no slot.
+//
+// The slots are shared by all bitmaps on d, so drawing one changes any
+// earlier one still on screen; only one at a time can be shown. It fails
+// without writing anything if the bitmap runs off the display or needs more
+// slots than are free, at most eight. The cursor is left where it was.
+func (d *Dev) DrawBitmap(line, col byte, bmp [][]bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	width := 0
+	for _, row := range bmp {
+		width = max(width, len(row))
+	}
+	rows, cols := (len(bmp)+7)/8, (width+4)/5
+	if line < 1 || int(line)-1+rows > int(d.opts.Lines) || int(col)+cols > int(d.opts.Cols) {
+		return fmt.Errorf("lcd1602: %dx%d pixel bitmap needs %dx%d cells from line %d col %d, display is %dx%d", width, len(bmp), cols, rows, line, col, d.opts.Cols, d.opts.Lines)
+	}
+	rom := romTable(d.opts.RomVariant)
+	solid := rom != nil && rom[0xFF] == '█'
+	cells := make([][][8]byte, rows)
+	var patterns [][8]byte
+	seen := make(map[[8]byte]bool)
+	for r := range cells {
+		cells[r] = make([][8]byte, cols)
+		for c := range cells[r] {
+			var p [8]byte
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 5; x++ {
+					if py, px := r*8+y, c*5+x; py < len(bmp) && px < len(bmp[py]) && bmp[py][px] {
+						p[y] |= 0x10 >> x
+					}
+				}
+			}
+			cells[r][c] = p
+			if p == ([8]byte{}) || solid && p == solidGlyph || seen[p] {
+				continue
+			}
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	free := 0
+	for _, o := range d.cgram {
+		if o == cgramFree || o == cgramBitmap {
+			free++
+		}
+	}
+	if len(patterns) > free {
+		return fmt.Errorf("lcd1602: bitmap needs %d CGRAM slots, %d are free", len(patterns), free)
+	}
+	for slot, o := range d.cgram {
+		if o == cgramBitmap {
+			d.cgram[slot] = cgramFree
+		}
+	}
+	codes := map[[8]byte]byte{{}: ' '}
+	if solid {
+		codes[solidGlyph] = 0xFF
+	}
+	for _, p := range patterns {
+		slot, err := d.defineChar(p, cgramBitmap)
+		if err != nil {
+			return err
+		}
+		codes[p] = slot
+	}
+	cur := d.saveCursor()
+	for r, row := range cells {
+		if err := d.setPosition(line+byte(r), col); err != nil {
+			return err
+		}
+		run := make([]byte, len(row))
+		for c, p := range row {
+			run[c] = codes[p]
+		}
+		if _, err := d.writeData(run); err != nil {
+			return err
+		}
+	}
+	return d.restoreCursor(cur)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024 Tim St. Pierre
+Buffered rendering for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BeginBatch starts collecting writes in memory instead of sending them.
+//
+// Until Flush, Write, WriteString, WriteChar, SetPosition, Clear and Home
+// only update an in-memory copy of the screen. Other commands, such as
+// cursor or backlight changes, still go straight to the display.
+func (d *Dev) BeginBatch() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batch != nil {
+		return
+	}
+	d.batch = newFrame(d.opts.Lines, d.opts.Cols)
+	for i := range d.batch {
+		copy(d.batch[i], d.shown[i])
+	}
+}
+
+// Flush ends a batch started with BeginBatch, writing only the cells that
+// differ from what is on screen. Adjacent changed cells are sent as one run
+// so each run costs a single positioning command. The cursor is left where
+// the batched writes put it.
+func (d *Dev) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batch == nil {
+		return nil
+	}
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	next, cur := d.batch, d.saveCursor()
+	d.batch = nil
+	for i := range next {
+		prev := append([]byte(nil), d.shown[i]...)
+		if err := d.writeDiff(byte(i+1), 0, prev, next[i]); err != nil {
+			return err
+		}
+	}
+	return d.restoreCursor(cur)
+}
+
+// Render makes the screen show frame, one row per line: rows are
+// translated like WriteString, truncated or padded with spaces to the width,
+// and rows past the last line are ignored. Lines frame has no row for are
+// blanked. Only cells that differ from what is already on screen are sent,
+// and the cursor is left where it was.
+func (d *Dev) Render(frame [][]rune) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cur := d.shown
+	if d.batch != nil {
+		cur = d.batch
+	}
+	saved := d.saveCursor()
+	for i := range cur {
+		var row []rune
+		if i < len(frame) {
+			row = frame[i]
+		}
+		prev := append([]byte(nil), cur[i]...)
+		if err := d.writeDiff(byte(i+1), 0, prev, d.frameRow(row)); err != nil {
+			return err
+		}
+	}
+	return d.restoreCursor(saved)
+}
+
+// WriteReplace makes line show s, translated like WriteString and truncated
+// or padded with spaces to the width, sending only the cells that differ
+// from what is already there: a status field rewritten every second costs
+// a positioned write per changed run rather than the whole line. The
+// cursor is left where it was.
+func (d *Dev) WriteReplace(line byte, s string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if line < 1 || line > d.opts.Lines {
+		return fmt.Errorf("lcd1602 %x: %w: %d, want 1-%d", d.opts.I2CAddr, ErrLineOutOfRange, line, d.opts.Lines)
+	}
+	cur := d.shown
+	if d.batch != nil {
+		cur = d.batch
+	}
+	saved := d.saveCursor()
+	prev := append([]byte(nil), cur[line-1]...)
+	if err := d.writeDiff(line, 0, prev, d.frameRow([]rune(s))); err != nil {
+		return err
+	}
+	return d.restoreCursor(saved)
+}
+
+// frameRow translates row to a full line of character codes, truncated or
+// padded with spaces.
+func (d *Dev) frameRow(row []rune) []byte {
+	next := bytes.Repeat([]byte{' '}, int(d.opts.Cols))
+	for j, r := range row {
+		if j >= len(next) {
+			break
+		}
+		next[j] = d.mapRune(r)
+	}
+	return next
+}
+
+// Snapshot returns what the driver believes is on screen, one line per row
+// separated by newlines, with blanks as spaces.
+//
+// It is a readback of the driver's own record of what it wrote, not of the
+// controller's DDRAM, so after a glitch or raw commands it may not match the
+// pixels. Cells hold raw character codes, so CGRAM glyphs show as bytes 0-7.
+// Pending batched writes are not included until Flush.
+func (d *Dev) Snapshot() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(bytes.Join(d.shown, []byte{'\n'}))
+}
+
+// WriteTo writes what the driver believes is on screen to w as an ASCII
+// box, one row per line, for logs and golden files:
+//
+//	+----------------+
+//	|Hello, world    |
+//	|                |
+//	+----------------+
+//
+// Codes outside printable ASCII are shown as their glyph from the
+// Opts.RomVariant table, or as '?' for CGRAM glyphs and codes it lacks. Like
+// Snapshot it reflects the driver's record, not the controller's DDRAM.
+func (d *Dev) WriteTo(w io.Writer) (int64, error) {
+	d.mu.Lock()
+	border := "+" + strings.Repeat("-", int(d.opts.Cols)) + "+\n"
+	var b strings.Builder
+	b.WriteString(border)
+	rom := romTable(d.opts.RomVariant)
+	for _, row := range d.shown {
+		b.WriteByte('|')
+		for _, c := range row {
+			switch {
+			case c >= 0x20 && c < 0x7F && (rom == nil || rom[c] == rune(c)):
+				b.WriteByte(c)
+			case rom != nil && rom[c] != 0:
+				b.WriteRune(rom[c])
+			default:
+				b.WriteByte('?')
+			}
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(border)
+	d.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// store records c in frame at the cell under the address counter. Writes to
+// DDRAM that is not visible are dropped.
+func (d *Dev) store(frame [][]byte, c byte) {
+	if line, col, ok := d.position(); ok {
+		frame[line-1][col] = c
+	}
+}
+
+// newFrame returns a blank lines by cols cell buffer.
+func newFrame(lines, cols uint8) [][]byte {
+	frame := make([][]byte, lines)
+	for i := range frame {
+		frame[i] = make([]byte, cols)
+	}
+	blank(frame)
+	return frame
+}
+
+// blank fills frame with spaces.
+func blank(frame [][]byte) {
+	for _, row := range frame {
+		for i := range row {
+			row[i] = ' '
+		}
+	}
+}
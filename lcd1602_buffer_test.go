@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 batched rendering
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkRedraw compares the port writes of a status screen updated
+// through BeginBatch and Flush with redrawing both lines every time. Only
+// the temperature digits change between updates.
+func BenchmarkRedraw(b *testing.B) {
+	lines := func(i int) (string, string) {
+		return fmt.Sprintf("Temp %3d C", 20+i%10), "Fan auto"
+	}
+	for _, bb := range []struct {
+		name string
+		draw func(d *Dev, l1, l2 string) error
+	}{
+		{"Flush", func(d *Dev, l1, l2 string) error {
+			d.BeginBatch()
+			if err := d.SetPosition(1, 0); err != nil {
+				return err
+			}
+			if _, err := d.WriteString(l1); err != nil {
+				return err
+			}
+			if err := d.SetPosition(2, 0); err != nil {
+				return err
+			}
+			if _, err := d.WriteString(l2); err != nil {
+				return err
+			}
+			return d.Flush()
+		}},
+		{"Full", func(d *Dev, l1, l2 string) error {
+			if err := d.WriteLine(1, l1); err != nil {
+				return err
+			}
+			return d.WriteLine(2, l2)
+		}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			d, r, _ := newReadyDev(b, DefaultOpts)
+			writes := 0
+			for i := 0; i < b.N; i++ {
+				l1, l2 := lines(i)
+				if err := bb.draw(d, l1, l2); err != nil {
+					b.Fatal(err)
+				}
+				writes += len(r.take())
+			}
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		})
+	}
+}
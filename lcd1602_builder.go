@@ -0,0 +1,96 @@
+/*
+Copyright 2024 Tim St. Pierre
+Options builder for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// OptsBuilder assembles Opts starting from DefaultOpts, so fields that are
+// not set keep working defaults rather than zero values. Use the Opts struct
+// directly for fields the builder does not cover.
+type OptsBuilder struct {
+	opts Opts
+}
+
+// NewOpts returns a builder holding DefaultOpts.
+func NewOpts() *OptsBuilder {
+	return &OptsBuilder{opts: DefaultOpts}
+}
+
+// Addr sets the I²C address.
+func (b *OptsBuilder) Addr(addr uint16) *OptsBuilder {
+	b.opts.I2CAddr = addr
+	return b
+}
+
+// Size sets the geometry, columns first as in "20x4".
+func (b *OptsBuilder) Size(cols, lines uint8) *OptsBuilder {
+	b.opts.Cols, b.opts.Lines = cols, lines
+	return b
+}
+
+// CharDelay sets the extra wait after each character.
+func (b *OptsBuilder) CharDelay(t time.Duration) *OptsBuilder {
+	b.opts.CharDelay = t
+	return b
+}
+
+// BacklightActiveLow sets whether the backlight switches on with its pin
+// low.
+func (b *OptsBuilder) BacklightActiveLow(v bool) *OptsBuilder {
+	b.opts.BacklightActiveLow = v
+	return b
+}
+
+// PinMap sets the backpack wiring.
+func (b *OptsBuilder) PinMap(p PinMap) *OptsBuilder {
+	b.opts.PinMap = p
+	return b
+}
+
+// Font sets the character font.
+func (b *OptsBuilder) Font(f Font) *OptsBuilder {
+	b.opts.Font = f
+	return b
+}
+
+// RomVariant sets the character ROM fitted to the controller.
+func (b *OptsBuilder) RomVariant(v RomVariant) *OptsBuilder {
+	b.opts.RomVariant = v
+	return b
+}
+
+// Overflow sets what happens to text written past the end of a line.
+func (b *OptsBuilder) Overflow(p OverflowPolicy) *OptsBuilder {
+	b.opts.Overflow = p
+	return b
+}
+
+// SupportsRead sets whether the backpack can read the controller back.
+func (b *OptsBuilder) SupportsRead(v bool) *OptsBuilder {
+	b.opts.SupportsRead = v
+	return b
+}
+
+// Logger sets where byte level traces go.
+func (b *OptsBuilder) Logger(l *slog.Logger) *OptsBuilder {
+	b.opts.Logger = l
+	return b
+}
+
+// Build validates the options and returns a copy of them.
+func (b *OptsBuilder) Build() (*Opts, error) {
+	opts := b.opts
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := opts.i2cAddr(); err != nil {
+		return nil, fmt.Errorf("lcd1602 %x: %w", opts.I2CAddr, err)
+	}
+	return &opts, nil
+}
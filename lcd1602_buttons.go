@@ -0,0 +1,58 @@
+/*
+Copyright 2024 Tim St. Pierre
+Button inputs for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"time"
+)
+
+// debounceTries bounds how many readings Debounce takes before giving up.
+const debounceTries = 10
+
+// ReadButtons reads the buttons on the input expander at Opts.InputAddr and
+// returns the ones in mask that are pressed as 1 bits. Buttons are wired
+// from an expander pin to ground, so a pressed button reads low; the bits
+// are inverted to match. The read shares the display's lock, so it never
+// lands in the middle of a write on the same bus.
+//
+// Without Opts.InputAddr it returns an error wrapping ErrUnsupported.
+func (d *Dev) ReadButtons(mask byte) (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOpen(); err != nil {
+		return 0, err
+	}
+	if d.input == nil {
+		return 0, fmt.Errorf("lcd1602: %w: no Opts.InputAddr for buttons", ErrUnsupported)
+	}
+	var buf [1]byte
+	if err := d.input.Tx(nil, buf[:]); err != nil {
+		return 0, fmt.Errorf("lcd1602: buttons at %#x: %w", d.opts.InputAddr, err)
+	}
+	return ^buf[0] & mask, nil
+}
+
+// Debounce is ReadButtons for a mechanical switch: it reads again after
+// settle until two readings in a row agree, and returns that one. It gives
+// up with an error if the buttons are still changing after ten readings.
+func (d *Dev) Debounce(mask byte, settle time.Duration) (byte, error) {
+	v, err := d.ReadButtons(mask)
+	if err != nil {
+		return 0, err
+	}
+	for i := 1; i < debounceTries; i++ {
+		d.opts.clock().Sleep(settle)
+		w, err := d.ReadButtons(mask)
+		if err != nil {
+			return 0, err
+		}
+		if w == v {
+			return v, nil
+		}
+		v = w
+	}
+	return v, fmt.Errorf("lcd1602: buttons %#x did not settle within %d readings", mask, debounceTries)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Tim St. Pierre
+Bus capture for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"time"
+)
+
+// Transaction is one byte latched onto the expander port, decoded into the
+// LCD lines it drives.
+type Transaction struct {
+	Time      time.Time
+	Port      byte // raw port byte
+	Nibble    byte // D7-D4 as bits 3-0
+	RS        bool // data rather than instruction
+	RW        bool // read
+	EN        bool
+	Backlight bool // backlight pin high, whatever its polarity
+}
+
+// captureRing holds the last Opts.CaptureBytes port writes.
+type captureRing struct {
+	buf  []Transaction
+	next int
+	full bool
+}
+
+// capture records port byte b if Opts.CaptureBytes is set.
+func (d *Dev) capture(b byte) {
+	if d.ring == nil {
+		return
+	}
+	r := d.ring
+	bit := func(pin byte) bool { return b&(1<<pin) != 0 }
+	var nibble byte
+	for i, pin := range []byte{d.pins.D4, d.pins.D5, d.pins.D6, d.pins.D7} {
+		if bit(pin) {
+			nibble |= 1 << i
+		}
+	}
+	r.buf[r.next] = Transaction{
+		Time:      d.opts.clock().Now(),
+		Port:      b,
+		Nibble:    nibble,
+		RS:        bit(d.pins.RS),
+		RW:        bit(d.pins.RW),
+		EN:        bit(d.pins.EN),
+		Backlight: bit(d.pins.Backlight),
+	}
+	r.next++
+	if r.next == len(r.buf) {
+		r.next, r.full = 0, true
+	}
+}
+
+// DumpTransactions returns the captured port writes, oldest first. It
+// returns nil unless Opts.CaptureBytes is set.
+func (d *Dev) DumpTransactions() []Transaction {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.ring
+	if r == nil {
+		return nil
+	}
+	if !r.full {
+		return append([]Transaction(nil), r.buf[:r.next]...)
+	}
+	return append(append([]Transaction(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2024 Tim St. Pierre
+Custom character (CGRAM) support for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+)
+
+// CGRAMSlots is the number of programmable 5x8 glyphs the controller holds.
+const CGRAMSlots = 8
+
+// cgramOwner records who loaded a CGRAM slot.
+type cgramOwner uint8
+
+const (
+	cgramFree    cgramOwner = iota
+	cgramUser               // CreateChar or DefineChar
+	cgramBar                // ProgressBar
+	cgramDegree             // WriteTemperature
+	cgramBig                // BigDigits
+	cgramSpinner            // Spinner
+	cgramScratch            // WriteGlyph, until its cell is overwritten
+	cgramBitmap             // DrawBitmap
+)
+
+func (o cgramOwner) String() string {
+	names := [...]string{"free", "user", "bar", "degree", "big", "spinner", "glyph", "bitmap"}
+	if int(o) < len(names) {
+		return names[o]
+	}
+	return fmt.Sprintf("owner%d", o)
+}
+
+// CreateChar loads a 5x8 glyph into one of the eight CGRAM slots.
+//
+// Each pattern byte is one row, top first, using the low five bits. Print the
+// glyph afterwards by writing the slot number as a character. The slot is
+// marked as taken until FreeChar, so DefineChar and the built-in glyph
+// helpers leave it alone.
+func (d *Dev) CreateChar(slot byte, pattern [8]byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.createChar(slot, pattern, cgramUser)
+}
+
+// DefineChar loads pattern into a free CGRAM slot and returns the slot, which
+// is also the character code that prints it. It fails when all eight slots
+// are taken.
+func (d *Dev) DefineChar(pattern [8]byte) (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.defineChar(pattern, cgramUser)
+}
+
+// FreeChar releases a slot taken by CreateChar, DefineChar or a built-in
+// glyph helper so it can be handed out again, e.g. to switch from a
+// ProgressBar to BigDigits. The glyph stays in CGRAM until
+// the slot is reused.
+func (d *Dev) FreeChar(slot byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if slot >= CGRAMSlots {
+		return fmt.Errorf("lcd1602: CGRAM slot %d out of range 0-%d", slot, CGRAMSlots-1)
+	}
+	d.cgram[slot] = cgramFree
+	return nil
+}
+
+// LoadFont loads all eight CGRAM slots in one run, glyph n into slot n, and
+// marks them taken as CreateChar does. The CGRAM address counter runs on from
+// one slot into the next, so this costs two address commands and 64 data
+// writes rather than sixteen commands. Rows must only use the low five bits.
+func (d *Dev) LoadFont(glyphs [CGRAMSlots][8]byte) error {
+	for slot, pattern := range glyphs {
+		for i, row := range pattern {
+			if row > 0x1F {
+				return fmt.Errorf("lcd1602: glyph %d row %d is %#x, only 5 bits wide", slot, i, row)
+			}
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cgram = [CGRAMSlots]cgramOwner{}
+	err := d.both(func() error {
+		if err := d.command(CMD_CGRAM_Set); err != nil {
+			return err
+		}
+		for _, pattern := range glyphs {
+			for _, row := range pattern {
+				if err := d.write(row, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for slot := range glyphs {
+		d.cgram[slot] = cgramUser
+	}
+	return d.restoreDDRAM()
+}
+
+// WriteGlyph shows pattern in the cell at col on line without keeping a
+// CGRAM slot for it: a free slot is borrowed and handed back as soon as
+// anything else is written to that cell, the screen is cleared or
+// ReleaseGlyph is called. It fails if no slot is free. The cursor ends after
+// the cell.
+func (d *Dev) WriteGlyph(line, col byte, pattern [8]byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	addr := d.addr
+	slot, err := d.defineChar(pattern, cgramScratch)
+	if err != nil {
+		return err
+	}
+	if _, err := d.writeData([]byte{slot}); err != nil {
+		d.cgram[slot] = cgramFree
+		return err
+	}
+	d.scratchCell[slot] = addr
+	return nil
+}
+
+// ReleaseGlyph hands back the slot borrowed by WriteGlyph for the cell at
+// col on line, if any. The cell keeps showing the glyph until the slot is
+// loaded with something else, so overwrite the cell first.
+func (d *Dev) ReleaseGlyph(line, col byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if line < 1 || line > d.opts.Lines || col >= d.opts.Cols {
+		return
+	}
+	d.releaseScratch(d.address(line, col), 0xFF)
+}
+
+// releaseScratch frees any WriteGlyph slot showing at DDRAM address addr,
+// now that c is written there. Writing the slot's own code keeps it.
+func (d *Dev) releaseScratch(addr, c byte) {
+	for slot, o := range d.cgram {
+		if o == cgramScratch && d.scratchCell[slot] == addr && byte(slot) != c {
+			d.cgram[slot] = cgramFree
+		}
+	}
+}
+
+func (d *Dev) defineChar(pattern [8]byte, owner cgramOwner) (byte, error) {
+	for slot, o := range d.cgram {
+		if o == cgramFree {
+			return byte(slot), d.createChar(byte(slot), pattern, owner)
+		}
+	}
+	return 0, fmt.Errorf("lcd1602: all %d CGRAM slots are in use", CGRAMSlots)
+}
+
+// reserve loads patterns into consecutive slots starting at first for a
+// built-in helper, unless owner already holds them all. Only free slots are
+// taken over: a slot held by the caller or by another helper, whose glyph
+// may still be on screen, is never overwritten.
+func (d *Dev) reserve(owner cgramOwner, first byte, patterns [][8]byte) error {
+	held := true
+	for i := range patterns {
+		switch o := d.cgram[first+byte(i)]; o {
+		case owner:
+		case cgramFree:
+			held = false
+		default:
+			return fmt.Errorf("lcd1602: CGRAM slot %d is in use by %s", first+byte(i), o)
+		}
+	}
+	if held {
+		return nil
+	}
+	for i, pattern := range patterns {
+		if err := d.createChar(first+byte(i), pattern, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slotOf returns the first slot held by owner.
+func (d *Dev) slotOf(owner cgramOwner) (byte, bool) {
+	for slot, o := range d.cgram {
+		if o == owner {
+			return byte(slot), true
+		}
+	}
+	return 0, false
+}
+
+func (d *Dev) createChar(slot byte, pattern [8]byte, owner cgramOwner) error {
+	if slot >= CGRAMSlots {
+		return fmt.Errorf("lcd1602: CGRAM slot %d out of range 0-%d", slot, CGRAMSlots-1)
+	}
+	// Until the whole pattern is in, nobody holds a usable glyph here.
+	d.cgram[slot] = cgramFree
+	// Each controller has its own CGRAM, so both are loaded.
+	err := d.both(func() error {
+		if err := d.command(CMD_CGRAM_Set | slot<<3); err != nil {
+			return err
+		}
+		for _, row := range pattern {
+			if err := d.write(row, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.cgram[slot] = owner
+	return d.restoreDDRAM()
+}
+
+// restoreDDRAM points the address counters back at DDRAM after a CGRAM
+// load, so the next Write lands where the caller left the cursor. Unlike
+// restoreCursor it does so in batch mode too.
+func (d *Dev) restoreDDRAM() error {
+	if d.dual() {
+		// The other controller's own position is not tracked; anything
+		// written there sets it first.
+		d.ctrl ^= 1
+		err := d.command(CMD_DDRAM_Set)
+		d.ctrl ^= 1
+		if err != nil {
+			return err
+		}
+	}
+	return d.command(CMD_DDRAM_Set | d.addr&0x7F)
+}
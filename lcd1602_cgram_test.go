@@ -0,0 +1,119 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 custom characters
+*/
+package lcd1602
+
+import (
+	"slices"
+	"testing"
+)
+
+var heart = [8]byte{0x00, 0x0A, 0x1F, 0x1F, 0x0E, 0x04, 0x00, 0x00}
+
+// rows returns the data writes that load pattern into CGRAM.
+func rows(pattern [8]byte) []op {
+	var ops []op
+	for _, row := range pattern {
+		ops = append(ops, op{data: true, b: row})
+	}
+	return ops
+}
+
+func TestCreateChar(t *testing.T) {
+	for _, slot := range []byte{0, 3, 7} {
+		d, r, _ := newReadyDev(t, DefaultOpts)
+		if err := d.SetPosition(2, 3); err != nil {
+			t.Fatal(err)
+		}
+		r.take()
+		if err := d.CreateChar(slot, heart); err != nil {
+			t.Fatal(err)
+		}
+		want := []op{cmd(CMD_CGRAM_Set | slot<<3)}
+		want = append(want, rows(heart)...)
+		// The cursor goes back to where it was, line 2 col 3.
+		want = append(want, cmd(CMD_DDRAM_Set|0x43))
+		if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+			t.Errorf("CreateChar(%d) = %v, want %v", slot, got, want)
+		}
+	}
+}
+
+func TestCreateCharSlotOutOfRange(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	if err := d.CreateChar(CGRAMSlots, heart); err == nil {
+		t.Error("CreateChar(8) succeeded")
+	}
+	if raw := r.take(); len(raw) != 0 {
+		t.Errorf("CreateChar(8) wrote %x", raw)
+	}
+}
+
+func TestLoadFont(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	if err := d.SetPosition(1, 4); err != nil {
+		t.Fatal(err)
+	}
+	r.take()
+	var font [CGRAMSlots][8]byte
+	for slot := range font {
+		for row := range font[slot] {
+			font[slot][row] = byte(slot*8+row) & 0x1F
+		}
+	}
+	if err := d.LoadFont(font); err != nil {
+		t.Fatal(err)
+	}
+	// One address command, the 64 rows back to back, then the cursor put
+	// back.
+	want := []op{cmd(CMD_CGRAM_Set)}
+	for _, pattern := range font {
+		want = append(want, rows(pattern)...)
+	}
+	want = append(want, cmd(CMD_DDRAM_Set|0x04))
+	if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for slot, o := range d.cgram {
+		if o != cgramUser {
+			t.Errorf("slot %d held by %s, want user", slot, o)
+		}
+	}
+}
+
+func TestReserveHeldSlots(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	if err := d.CreateChar(2, heart); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ProgressBar(2, 0.5); err == nil {
+		t.Error("ProgressBar took over a CreateChar slot")
+	}
+	if err := d.FreeChar(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ProgressBar(2, 0.5); err != nil {
+		t.Fatalf("ProgressBar on free slots: %v", err)
+	}
+	// Another helper's slots are not taken over either, as its glyphs may
+	// still be on screen.
+	big, err := NewBigDigits(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := big.WriteBigNumber(0, 42); err == nil {
+		t.Fatal("BigDigits took over the ProgressBar slots")
+	}
+	if d.cgram[0] != cgramBar {
+		t.Errorf("slot 0 held by %s, want bar", d.cgram[0])
+	}
+	for slot := byte(0); slot < CGRAMSlots; slot++ {
+		if err := d.FreeChar(slot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := big.WriteBigNumber(0, 42); err != nil {
+		t.Errorf("BigDigits after FreeChar: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Tim St. Pierre
+Character ROM translation for lcd1602 character display
+*/
+package lcd1602
+
+const defaultSubstituteByte = '?'
+
+// A00CharMap maps runes outside ASCII to their codes in the A00 (Japanese)
+// character ROM fitted to most HD44780 modules. It also carries look-alikes
+// A00Rom does not list, such as ° for the handakuten at 0xDF.
+//
+// The A00 ROM has no ± glyph; load one with CreateChar if needed.
+var A00CharMap = map[rune]byte{
+	'¥': 0x5C,
+	'→': 0x7E,
+	'←': 0x7F,
+	'・': 0xA5,
+	'°': 0xDF,
+	'α': 0xE0,
+	'ä': 0xE1,
+	'β': 0xE2,
+	'ε': 0xE3,
+	'µ': 0xE4,
+	'μ': 0xE4,
+	'σ': 0xE5,
+	'ρ': 0xE6,
+	'√': 0xE8,
+	'¢': 0xEC,
+	'£': 0xED,
+	'ñ': 0xEE,
+	'ö': 0xEF,
+	'θ': 0xF2,
+	'∞': 0xF3,
+	'Ω': 0xF4,
+	'ü': 0xF5,
+	'Σ': 0xF6,
+	'π': 0xF7,
+	'÷': 0xFD,
+	'█': 0xFF,
+}
+
+// mapRune returns the ROM code that displays r.
+//
+// ASCII passes through untouched. Anything else is looked up in
+// Opts.CharMap, then in the ROM table for Opts.RomVariant, falling back to
+// Opts.SubstituteByte, which Opts.OnSubstitute hears about.
+func (d *Dev) mapRune(r rune) byte {
+	if r < 0x80 {
+		return byte(r)
+	}
+	charMap := d.opts.CharMap
+	if charMap == nil && d.opts.RomVariant == RomA00 {
+		charMap = A00CharMap
+	}
+	if b, ok := charMap[r]; ok {
+		return b
+	}
+	if rom := romTable(d.opts.RomVariant); rom != nil {
+		if b, ok := RuneToByte(r, *rom); ok {
+			return b
+		}
+	}
+	if d.opts.OnSubstitute != nil {
+		d.opts.OnSubstitute(r)
+	}
+	if d.opts.SubstituteByte != 0 {
+		return d.opts.SubstituteByte
+	}
+	return defaultSubstituteByte
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 character translation
+*/
+package lcd1602
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  byte
+		want string
+	}{
+		{"default", 0, "a?b?"},
+		{"set", '*', "a*b*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []rune
+			o := DefaultOpts
+			o.SubstituteByte = tt.sub
+			o.OnSubstitute = func(r rune) { got = append(got, r) }
+			d, r, _ := newReadyDev(t, o)
+			// Each rune is several bytes of UTF-8 but only one cell.
+			n, err := d.WriteString("a😀b中")
+			if err != nil || n != len("a😀b中") {
+				t.Fatalf("WriteString = %d, %v", n, err)
+			}
+			if ops, want := latched(t, d, r.take()), text(tt.want); !slices.Equal(ops, want) {
+				t.Errorf("sent %v, want %v", ops, want)
+			}
+			if want := []rune{'😀', '中'}; !slices.Equal(got, want) {
+				t.Errorf("OnSubstitute got %q, want %q", got, want)
+			}
+		})
+	}
+}
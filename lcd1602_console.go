@@ -0,0 +1,94 @@
+/*
+Copyright 2024 Tim St. Pierre
+Scrolling console for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// LineWriter turns the display into a small scrolling console, for log
+// output written with fmt.Fprintln and the like. Each complete line goes to
+// the next row, and once the rows are full they scroll up one per line,
+// the newest at the bottom. Lines longer than the display are truncated, and
+// tabs are expanded to stops of four columns.
+//
+// Only the cells a scroll changes are rewritten, as with Render. The cursor
+// is left where it was.
+type LineWriter struct {
+	d       *Dev
+	mu      sync.Mutex
+	rows    []string // lines on screen, oldest first
+	pending []byte   // start of a line without its '\n' yet
+}
+
+// NewLineWriter returns a console writing to d.
+func NewLineWriter(d *Dev) *LineWriter {
+	return &LineWriter{d: d}
+}
+
+// Write buffers p and shows every line it completes. It reports all of p as
+// written unless the display fails.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	i := bytes.LastIndexByte(w.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	for _, line := range strings.Split(string(w.pending[:i]), "\n") {
+		w.push(line)
+	}
+	w.pending = append(w.pending[:0], w.pending[i+1:]...)
+	if err := w.show(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush shows a line still waiting for its '\n', as if it had one.
+func (w *LineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	w.push(string(w.pending))
+	w.pending = w.pending[:0]
+	return w.show()
+}
+
+// push adds line at the bottom, dropping the oldest rows beyond the
+// display's lines.
+func (w *LineWriter) push(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	lines, _ := w.d.Dimensions()
+	w.rows = append(w.rows, b.String())
+	if extra := len(w.rows) - int(lines); extra > 0 {
+		w.rows = append(w.rows[:0], w.rows[extra:]...)
+	}
+}
+
+func (w *LineWriter) show() error {
+	frame := make([][]rune, len(w.rows))
+	for i, row := range w.rows {
+		frame[i] = []rune(row)
+	}
+	return w.d.Render(frame)
+}
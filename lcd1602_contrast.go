@@ -0,0 +1,35 @@
+/*
+Copyright 2024 Tim St. Pierre
+Contrast control for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+)
+
+// SetContrast sets the panel contrast, 0 being the faintest and 255 the
+// darkest, through an MCP4725 DAC on the contrast pin (V0) at
+// Opts.ContrastAddr.
+//
+// Most backpacks set contrast with a trimmer pot instead, and then
+// SetContrast returns an error wrapping ErrUnsupported. A display that
+// initialises fine yet shows nothing, or only a row of solid blocks, usually
+// needs that pot turned.
+func (d *Dev) SetContrast(level uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOpen(); err != nil {
+		return err
+	}
+	if d.contrast == nil {
+		return fmt.Errorf("lcd1602: %w: contrast is set by the pot, no Opts.ContrastAddr", ErrUnsupported)
+	}
+	// Contrast rises as V0 falls, so the level is inverted. Spread the 8 bit
+	// level over the 12 bit DAC and send it as a fast mode write.
+	v := 0xFFF - (uint16(level)<<4 | uint16(level)>>4)
+	if err := d.contrast.Tx([]byte{byte(v >> 8), byte(v)}, nil); err != nil {
+		return fmt.Errorf("lcd1602: contrast DAC: %w: %w", ErrBusWrite, err)
+	}
+	return nil
+}
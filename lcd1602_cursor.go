@@ -0,0 +1,57 @@
+/*
+Copyright 2024 Tim St. Pierre
+Chained positioned writes for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// WriteCursor chains positioned writes, keeping the first error for Err so
+// that layout code needs a single check at the end:
+//
+//	err := dev.At(1, 0).Print("Temp:").At(1, 6).Int(t).Err()
+//
+// After an error every later call does nothing.
+type WriteCursor struct {
+	d   *Dev
+	err error
+}
+
+// At starts a chain at col on line.
+func (d *Dev) At(line, col byte) *WriteCursor {
+	return (&WriteCursor{d: d}).At(line, col)
+}
+
+// At moves to col on line.
+func (c *WriteCursor) At(line, col byte) *WriteCursor {
+	if c.err == nil {
+		c.err = c.d.SetPosition(line, col)
+	}
+	return c
+}
+
+// Print writes s as WriteString does.
+func (c *WriteCursor) Print(s string) *WriteCursor {
+	if c.err == nil {
+		_, c.err = c.d.WriteString(s)
+	}
+	return c
+}
+
+// Printf formats according to format and writes the result.
+func (c *WriteCursor) Printf(format string, args ...interface{}) *WriteCursor {
+	return c.Print(fmt.Sprintf(format, args...))
+}
+
+// Int writes n in decimal.
+func (c *WriteCursor) Int(n int) *WriteCursor {
+	return c.Print(strconv.Itoa(n))
+}
+
+// Err returns the first error of the chain.
+func (c *WriteCursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2024 Tim St. Pierre
+Error values for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"errors"
+)
+
+// Errors returned by the driver wrap these, so callers can tell failures
+// apart with errors.Is, e.g. to retry on ErrBusWrite but give up on
+// ErrUnsupportedAddress.
+var (
+	// ErrUnsupported is wrapped by errors for features the backpack or
+	// transport lacks, such as SetContrast without a DAC or reads without
+	// Opts.SupportsRead.
+	ErrUnsupported = errors.New("not supported by this backpack")
+	// ErrUnsupportedAddress means Opts.I2CAddr is not a PCF8574 or
+	// PCF8574A address.
+	ErrUnsupportedAddress = errors.New("given address not supported by device")
+	// ErrLineOutOfRange and ErrColOutOfRange mean a position is not on
+	// the display.
+	ErrLineOutOfRange = errors.New("line out of range")
+	ErrColOutOfRange  = errors.New("col out of range")
+	// ErrBusWrite wraps the transport's error when a write to the
+	// backpack fails, after any Opts.WriteRetries.
+	ErrBusWrite = errors.New("bus write failed")
+	// ErrNoResponse is wrapped by Ping when the display does not answer;
+	// Reset may bring it back.
+	ErrNoResponse = errors.New("display not responding")
+	// ErrClosed is wrapped by every bus access after Close.
+	ErrClosed = errors.New("device closed")
+	// ErrBadInterval means an animation or scroll was given a step of
+	// zero or less.
+	ErrBadInterval = errors.New("interval must be positive")
+)
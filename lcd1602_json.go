@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Tim St. Pierre
+Configuration files for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsonDuration reads a time.Duration from a "1ms" style string, or from a
+// number of nanoseconds, and writes it back as a string.
+type jsonDuration time.Duration
+
+func (t jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(t).String())
+}
+
+func (t *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(b, &ns); err != nil {
+			return fmt.Errorf("lcd1602: duration must be a string like \"1ms\", got %s", b)
+		}
+		*t = jsonDuration(ns)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*t = jsonDuration(v)
+	return nil
+}
+
+// optsJSON overlays the duration fields of Opts with their string forms.
+// The outer fields shadow the embedded ones of the same name.
+type optsJSON struct {
+	*optsPlain
+	CharDelay        *jsonDuration `json:"char_delay"`
+	ClearDelay       *jsonDuration `json:"clear_delay"`
+	HomeDelay        *jsonDuration `json:"home_delay"`
+	EnableSettleTime *jsonDuration `json:"enable_settle_time"`
+	EnablePulseWidth *jsonDuration `json:"enable_pulse_width"`
+	RetryBackoff     *jsonDuration `json:"retry_backoff"`
+	PWMPeriod        *jsonDuration `json:"pwm_period"`
+}
+
+// optsPlain is Opts without its JSON methods, so encoding it does not
+// recurse.
+type optsPlain Opts
+
+func (o *Opts) jsonView() optsJSON {
+	return optsJSON{
+		optsPlain:        (*optsPlain)(o),
+		CharDelay:        (*jsonDuration)(&o.CharDelay),
+		ClearDelay:       (*jsonDuration)(&o.ClearDelay),
+		HomeDelay:        (*jsonDuration)(&o.HomeDelay),
+		EnableSettleTime: (*jsonDuration)(&o.EnableSettleTime),
+		EnablePulseWidth: (*jsonDuration)(&o.EnablePulseWidth),
+		RetryBackoff:     (*jsonDuration)(&o.RetryBackoff),
+		PWMPeriod:        (*jsonDuration)(&o.PWMPeriod),
+	}
+}
+
+// MarshalJSON writes o with durations as strings such as "1ms".
+func (o Opts) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.jsonView())
+}
+
+// UnmarshalJSON reads o, taking durations either as strings such as "1ms" or
+// as nanoseconds. Fields missing from b are left as they are.
+func (o *Opts) UnmarshalJSON(b []byte) error {
+	v := o.jsonView()
+	return json.Unmarshal(b, &v)
+}
+
+// OptsFromJSON parses display options from a configuration file and
+// validates them. Fields the file leaves out keep their DefaultOpts values,
+// so {"i2c_addr": 63} is a complete configuration. Logger, OnError,
+// OnSubstitute and Clock cannot be set from JSON.
+func OptsFromJSON(b []byte) (*Opts, error) {
+	opts := DefaultOpts
+	if err := json.Unmarshal(b, &opts); err != nil {
+		return nil, fmt.Errorf("lcd1602: parsing options: %w", err)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := opts.i2cAddr(); err != nil {
+		return nil, fmt.Errorf("lcd1602: %w", err)
+	}
+	return &opts, nil
+}
@@ -6,16 +6,202 @@ package lcd1602
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 )
 
+// Font selects the character cell height.
+type Font uint8
+
+const (
+	Font5x8  Font = iota // 5x8 dots, the default
+	Font5x10             // 5x10 dots, only available on 1-line displays
+)
+
+// RomVariant identifies the character ROM mask fitted to the controller.
+type RomVariant uint8
+
+const (
+	RomA00   RomVariant = iota // Japanese katakana ROM, the most common
+	RomA02                     // European ROM, Latin-1 like upper half
+	RomOther                   // unknown or clone ROM
+)
+
+// Clock is the time source the driver waits on, see Opts.Clock.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock is the Clock used when Opts.Clock is nil.
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// OverflowPolicy decides what Write and WriteString do with characters that
+// run past the last column of a line.
+type OverflowPolicy uint8
+
+const (
+	// OverflowTruncate drops the rest of the line, up to the next '\n'.
+	OverflowTruncate OverflowPolicy = iota
+	// OverflowWrap continues on the next line, stopping with
+	// io.ErrShortWrite after the last.
+	OverflowWrap
+	// OverflowRaw keeps feeding the controller, which carries on through
+	// off-screen DDRAM, or on 4 line parts into line 3 or 4.
+	OverflowRaw
+)
+
+// PinMap gives the expander bit (0-7) each LCD line is wired to.
+type PinMap struct {
+	RS        byte `json:"rs"`
+	RW        byte `json:"rw"`
+	EN        byte `json:"en"`
+	D4        byte `json:"d4"`
+	D5        byte `json:"d5"`
+	D6        byte `json:"d6"`
+	D7        byte `json:"d7"`
+	Backlight byte `json:"backlight"`
+}
+
+// DefaultPinMap is the wiring of the common PCF8574 backpack.
+var DefaultPinMap = PinMap{
+	RS:        RS,
+	RW:        WR,
+	EN:        EN,
+	D4:        D4,
+	D5:        D5,
+	D6:        D6,
+	D7:        D7,
+	Backlight: BACKLIGHT,
+}
+
 type Opts struct {
-	// The I²C slave address
-	I2CAddr uint16
+	// The I²C slave address, 0x20-0x27 for PCF8574 or 0x38-0x3F for
+	// PCF8574A backpacks
+	I2CAddr uint16 `json:"i2c_addr"`
 	// How many lines does the display have
-	Lines     uint8
-	Cols      uint8
-	CharDelay time.Duration
+	Lines uint8 `json:"lines"`
+	Cols  uint8 `json:"cols"`
+	// Extra wait after each character, on top of its execution time
+	CharDelay time.Duration `json:"char_delay"`
+	// Wait after Clear and Home, which take up to 1.52ms on the controller.
+	// Both default to 2ms when zero.
+	ClearDelay time.Duration `json:"clear_delay"`
+	HomeDelay  time.Duration `json:"home_delay"`
+	// Time the data lines settle before EN rises, and how long EN is held
+	// high. Both default to 40µs when zero; slow 3.3V clones may need more.
+	EnableSettleTime time.Duration `json:"enable_settle_time"`
+	EnablePulseWidth time.Duration `json:"enable_pulse_width"`
+	// Send each byte's nibble and EN pattern as one I²C transaction instead
+	// of six, cutting the per-character bus overhead. Most PCF8574 backpacks
+	// latch each byte of a multi-byte write in turn; turn it off for ones that
+	// misbehave. Ignored on SPI.
+	FastWrite bool `json:"fast_write"`
+	// What happens to text written past the end of a line. Defaults to
+	// OverflowTruncate. Only applies in the default left to right entry mode.
+	Overflow OverflowPolicy `json:"overflow"`
+	// The backpack's backlight transistor switches on with its pin low, as
+	// on some clones, so the backlight bit is inverted on every write.
+	BacklightActiveLow bool `json:"backlight_active_low"`
+	// DDRAM address of the first column of each line. The zero value selects
+	// the usual map: 0x00 and 0x40, with lines 3 and 4 continuing lines 1 and
+	// 2 at Cols and 0x40+Cols. See LineOffsets16x4Alt and Opts16x1Split for
+	// modules wired otherwise.
+	LineOffsets [4]byte `json:"line_offsets"`
+	// The panel is mounted upside down: lines and columns are numbered from
+	// the controller's last cell, and text runs right to left in DDRAM, so
+	// it reads in the right order. The characters themselves are still drawn
+	// from the ROM the right way up, so they look upside down; turning them
+	// needs CGRAM glyphs, which only hold eight, and is left to the caller.
+	Rotate180 bool `json:"rotate_180"`
+	// Start with the underline cursor shown, and with the cursor cell
+	// blinking. Both start off, which suits static readouts; see SetCursor
+	// and SetBlink.
+	ShowCursor bool `json:"show_cursor"`
+	Blink      bool `json:"blink"`
+	// Expander pin wired to E2 on a 40x4 module, which is two controllers
+	// sharing the data lines, each with its own enable: lines 1-2 go through
+	// EN and lines 3-4 through this pin. Zero means a single controller, so
+	// E2 cannot be on pin 0. The pin may take over RW when SupportsRead is
+	// off. Requires Lines to be 4.
+	//
+	// The display shift set by SetDisplayShift or SetEntryMode only moves
+	// the controller being written, so leave it off with two.
+	Enable2Pin byte `json:"enable2_pin"`
+	// I²C address of an MCP4725 DAC wired to the contrast pin (V0), on the
+	// same bus as the display, for SetContrast. Zero means contrast is set by
+	// a pot on the backpack. Ignored on SPI and GPIO.
+	ContrastAddr uint16 `json:"contrast_addr"`
+	// I²C address of a second PCF8574 with buttons on its pins, on the same
+	// bus as the display, for ReadButtons. Zero means there are none.
+	// Ignored on SPI and GPIO.
+	InputAddr uint16 `json:"input_addr"`
+	// Backpack wiring. The zero value selects DefaultPinMap.
+	PinMap PinMap `json:"pin_map"`
+	// How many times a failed port write is retried before giving up, and
+	// the wait before the first retry. With FastWrite the whole six byte
+	// burst is retried. Retries are off by default.
+	WriteRetries int           `json:"write_retries"`
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	// Attach to a controller that is already initialised, e.g. after an
+	// application restart without a power cycle: the reset sequence, its
+	// sleeps and the clear are skipped, so the screen does not flash. If the
+	// controller was not in fact initialised the display shows garbage until
+	// Reset. The driver's copy of the screen starts blank either way.
+	SkipInit bool `json:"skip_init"`
+	// Character font. Font5x10 requires Lines to be 1.
+	Font Font `json:"font"`
+	// The backpack wires RW to the expander, so the controller can be read.
+	// Many cheap backpacks tie RW to ground instead.
+	SupportsRead bool `json:"supports_read"`
+	// Poll the busy flag after each character instead of sleeping CharDelay.
+	// Requires SupportsRead.
+	UseBusyFlag bool `json:"use_busy_flag"`
+	// The backpack can take a software PWM on its backlight pin, see
+	// SetBrightness.
+	PWMBacklight bool `json:"pwm_backlight"`
+	// Length of one PWM cycle. Defaults to 10ms when zero.
+	PWMPeriod time.Duration `json:"pwm_period"`
+	// Character ROM on the controller. Defaults to RomA00.
+	RomVariant RomVariant `json:"rom_variant"`
+	// Translates runes written with WriteString to character ROM codes,
+	// ahead of the A00Rom or A02Rom table picked by RomVariant. Defaults to
+	// A00CharMap on RomA00 parts when nil.
+	CharMap map[rune]byte `json:"char_map"`
+	// Written for runes missing from CharMap and the ROM table, one per
+	// rune. Defaults to '?' when zero.
+	SubstituteByte byte `json:"substitute_byte"`
+	// Called with each rune replaced by SubstituteByte, e.g. to log text
+	// that cannot be shown, every time the rune is drawn. It runs with the
+	// driver's lock held, so it must not call back into the Dev.
+	OnSubstitute func(r rune) `json:"-"`
+	// Blank cells between repeats of a ScrollText marquee. Defaults to 4
+	// when zero.
+	ScrollGap uint8 `json:"scroll_gap"`
+	// Called with the error when a background write fails, in the backlight
+	// PWM, a ScrollController or AutoShift, whose errors cannot otherwise reach
+	// the caller. It runs on the background goroutine, without the driver's
+	// lock held, after the goroutine has finished, so it may call Halt,
+	// Close or the stop that started it. It may be called from several
+	// goroutines at once. Nil ignores such errors.
+	OnError func(error) `json:"-"`
+	// Keep the last CaptureBytes port writes for DumpTransactions, as a
+	// post-mortem trail. Zero disables capture.
+	CaptureBytes int `json:"capture_bytes"`
+	// Receives byte level traces at Debug level. Nil disables logging.
+	Logger *slog.Logger `json:"-"`
+	// Time source for the waits of the init sequence, commands, EN pulses,
+	// CharDelay, busy flag polls and retries, and of the foreground helpers
+	// such as TypeText, SelfTest and Debounce, and for DumpTransactions
+	// timestamps. A fake clock that returns from Sleep at once lets tests of
+	// code using the display run without real delays while still seeing
+	// them. Background animations, the backlight PWM and Screensaver keep
+	// real time. Nil uses the system clock.
+	Clock Clock `json:"-"`
 }
 
 var DefaultOpts = Opts{
@@ -25,14 +211,150 @@ var DefaultOpts = Opts{
 	CharDelay: 1 * time.Millisecond,
 }
 
+// DefaultOptsA is DefaultOpts for backpacks with the PCF8574A expander,
+// which answer at 0x38-0x3F instead of 0x20-0x27; 0x3F with no address
+// jumpers bridged.
+var DefaultOptsA = Opts{
+	I2CAddr:   0x3F,
+	Lines:     2,
+	Cols:      16,
+	CharDelay: 1 * time.Millisecond,
+}
+
+// Opts20x4 configures a 20 column, 4 line display (LCD2004) on the default
+// address.
+var Opts20x4 = Opts{
+	I2CAddr:   0x27,
+	Lines:     4,
+	Cols:      20,
+	CharDelay: 1 * time.Millisecond,
+}
+
+// LineOffsets16x4Alt is the line map of 16x4 modules that start lines 3 and
+// 4 at 0x14 and 0x54, as 20x4 modules do, rather than straight after lines 1
+// and 2.
+var LineOffsets16x4Alt = [4]byte{0x00, 0x40, 0x14, 0x54}
+
+// Opts16x1Split configures a "type 2" 16x1 module, whose one row is wired
+// as two 8 character halves at 0x00 and 0x40. The driver sees it as an 8x2
+// display: line 1 is the left half and line 2 the right half.
+var Opts16x1Split = Opts{
+	I2CAddr:     0x27,
+	Lines:       2,
+	Cols:        8,
+	CharDelay:   1 * time.Millisecond,
+	LineOffsets: [4]byte{0x00, 0x40},
+}
+
+const defaultSlowCommandDelay = 2 * time.Millisecond
+
+func (o *Opts) clearDelay() time.Duration {
+	if o.ClearDelay > 0 {
+		return o.ClearDelay
+	}
+	return defaultSlowCommandDelay
+}
+
+func (o *Opts) homeDelay() time.Duration {
+	if o.HomeDelay > 0 {
+		return o.HomeDelay
+	}
+	return defaultSlowCommandDelay
+}
+
+func (o *Opts) clock() Clock {
+	if o.Clock != nil {
+		return o.Clock
+	}
+	return realClock{}
+}
+
+// validate rejects geometries and timings the controller cannot handle.
+func (o *Opts) validate() error {
+	if o.Lines < 1 || o.Lines > 4 {
+		return fmt.Errorf("lcd1602: Lines must be 1-4, got %d", o.Lines)
+	}
+	if o.Cols < 1 || o.Cols > 40 {
+		return fmt.Errorf("lcd1602: Cols must be 1-40, got %d", o.Cols)
+	}
+	ddram := 80
+	if o.Enable2Pin != 0 {
+		if o.Lines != 4 {
+			return fmt.Errorf("lcd1602: Enable2Pin needs a 4-line display, got %d lines", o.Lines)
+		}
+		ddram = 160
+	}
+	if int(o.Lines)*int(o.Cols) > ddram {
+		return fmt.Errorf("lcd1602: %dx%d exceeds the %d character DDRAM", o.Cols, o.Lines, ddram)
+	}
+	if o.CharDelay < 0 {
+		return fmt.Errorf("lcd1602: CharDelay must not be negative, got %s", o.CharDelay)
+	}
+	if o.LineOffsets != ([4]byte{}) {
+		for i, start := range o.LineOffsets[:o.Lines] {
+			if start&^0x40 >= ddramLineLen || int(start&0x3F)+int(o.Cols) > ddramLineLen {
+				return fmt.Errorf("lcd1602: line %d at %#x does not fit %d columns in DDRAM", i+1, start, o.Cols)
+			}
+		}
+	}
+	pins := o.pinMap()
+	var used byte
+	for _, pin := range []byte{pins.RS, pins.RW, pins.EN, pins.D4, pins.D5, pins.D6, pins.D7, pins.Backlight} {
+		if pin > 7 || used&(1<<pin) != 0 {
+			return fmt.Errorf("lcd1602: PinMap %+v needs eight distinct pins 0-7", pins)
+		}
+		used |= 1 << pin
+	}
+	if e2 := o.Enable2Pin; e2 != 0 {
+		if e2 > 7 || e2 != pins.RW && used&(1<<e2) != 0 {
+			return fmt.Errorf("lcd1602: Enable2Pin %d must be 1-7 and not used by PinMap %+v", e2, pins)
+		}
+		if e2 == pins.RW && o.SupportsRead {
+			return errors.New("lcd1602: Enable2Pin on the RW pin rules out SupportsRead")
+		}
+	}
+	if o.UseBusyFlag && !o.SupportsRead {
+		return errors.New("lcd1602: UseBusyFlag needs SupportsRead")
+	}
+	if o.Font == Font5x10 && o.Lines > 1 {
+		return fmt.Errorf("lcd1602: 5x10 font needs a 1-line display, got %d lines", o.Lines)
+	}
+	return nil
+}
+
+const defaultEnableTiming = 40 * time.Microsecond
+
+func (o *Opts) enableSettleTime() time.Duration {
+	if o.EnableSettleTime > 0 {
+		return o.EnableSettleTime
+	}
+	return defaultEnableTiming
+}
+
+func (o *Opts) enablePulseWidth() time.Duration {
+	if o.EnablePulseWidth > 0 {
+		return o.EnablePulseWidth
+	}
+	return defaultEnableTiming
+}
+
+func (o *Opts) pinMap() PinMap {
+	if o.PinMap == (PinMap{}) {
+		return DefaultPinMap
+	}
+	return o.PinMap
+}
+
 func (o *Opts) i2cAddr() (uint16, error) {
 	switch o.I2CAddr {
 	case 0:
 		// Default address.
 		return 0x27, nil
-	case 0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27:
+	case 0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27: // PCF8574
+		return o.I2CAddr, nil
+	case 0x38, 0x39, 0x3A, 0x3B, 0x3C, 0x3D, 0x3E, 0x3F: // PCF8574A
 		return o.I2CAddr, nil
 	default:
-		return 0, errors.New("given address not supported by device")
+		return 0, fmt.Errorf("%w: %#x", ErrUnsupportedAddress, o.I2CAddr)
 	}
 }
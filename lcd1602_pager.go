@@ -0,0 +1,111 @@
+/*
+Copyright 2024 Tim St. Pierre
+Paged text for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Pager shows text that is longer than the whole screen one screenful at a
+// time. The text is wrapped as WriteWrapped does: at word boundaries to the
+// display width, words longer than a line are split, and '\n' starts a new
+// line. The last page is padded with blank lines.
+//
+// A Pager draws nothing until Page, Next or Prev is called; the first Next
+// or Prev draws page 0. The text is wrapped again whenever the display
+// geometry differs from the last wrap.
+type Pager struct {
+	d           *Dev
+	text        string
+	mu          sync.Mutex
+	lines, cols byte       // geometry pages was wrapped for
+	pages       [][]string // wrapped text, Lines rows per page
+	page        int        // page on screen, -1 before the first
+}
+
+// NewPager returns a pager for text on d.
+func NewPager(d *Dev, text string) *Pager {
+	return &Pager{d: d, text: text, page: -1}
+}
+
+// Pages returns the number of pages the text takes.
+func (p *Pager) Pages() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.paginate())
+}
+
+// Current returns the page last drawn, or -1 before any.
+func (p *Pager) Current() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.page
+}
+
+// Next draws the page after the current one. On the last page it redraws
+// the last page.
+func (p *Pager) Next() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pages := p.paginate()
+	return p.show(min(p.page+1, len(pages)-1))
+}
+
+// Prev draws the page before the current one. On the first page it redraws
+// the first page.
+func (p *Pager) Prev() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paginate()
+	return p.show(max(p.page-1, 0))
+}
+
+// Page draws page n, counting from 0.
+func (p *Pager) Page(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pages := p.paginate(); n < 0 || n >= len(pages) {
+		return fmt.Errorf("lcd1602: page %d out of range 0-%d", n, len(pages)-1)
+	}
+	return p.show(n)
+}
+
+// show draws page n, which must be in range.
+func (p *Pager) show(n int) error {
+	d := p.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, text := range p.pages[n] {
+		if err := d.writeAligned(byte(i+1), text, AlignLeft); err != nil {
+			return err
+		}
+	}
+	p.page = n
+	return nil
+}
+
+// paginate wraps the text for the display's current geometry, if it has
+// changed, and returns the pages.
+func (p *Pager) paginate() [][]string {
+	lines, cols := p.d.Dimensions()
+	if p.pages != nil && lines == p.lines && cols == p.cols {
+		return p.pages
+	}
+	rows := wrapLines(p.text, int(cols))
+	p.pages = nil
+	for len(rows) > 0 {
+		page := make([]string, lines)
+		n := copy(page, rows)
+		rows = rows[n:]
+		p.pages = append(p.pages, page)
+	}
+	if p.pages == nil {
+		p.pages = [][]string{make([]string, lines)}
+	}
+	p.lines, p.cols = lines, cols
+	p.page = min(p.page, len(p.pages)-1)
+	return p.pages
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 paged text
+*/
+package lcd1602
+
+import "testing"
+
+// TestPagerMatchesWriteWrapped checks the pager breaks lines exactly where
+// WriteWrapped does, screenful by screenful.
+func TestPagerMatchesWriteWrapped(t *testing.T) {
+	const s = "The quick brown fox jumps over the lazy dog.\nSupercalifragilistic words get split, and  runs of   spaces collapse.\n\nDone."
+	pd, _, _ := newReadyDev(t, DefaultOpts)
+	wd, _, _ := newReadyDev(t, DefaultOpts)
+	p := NewPager(pd, s)
+	pages := p.Pages()
+	rest := s
+	for i := 0; i < pages; i++ {
+		if err := p.Page(i); err != nil {
+			t.Fatal(err)
+		}
+		n, err := wd.WriteWrapped(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+		if got, want := pd.Snapshot(), wd.Snapshot(); got != want {
+			t.Errorf("page %d:\n%s\nWriteWrapped:\n%s", i, got, want)
+		}
+	}
+	if rest != "" {
+		t.Errorf("%d pages, but WriteWrapped has %q left", pages, rest)
+	}
+}
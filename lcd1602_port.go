@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Tim St. Pierre
+Transports for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/spi"
+)
+
+// port is the 8-bit output the LCD lines hang off. Every transport is driven
+// one whole byte at a time by write and enable, so anything that can latch a
+// byte, including a recorder in tests, can stand in for the bus.
+type port interface {
+	fmt.Stringer
+	writeByte(b byte) error
+}
+
+// portReader is implemented by ports that can sample the lines back, which
+// the busy flag read needs.
+type portReader interface {
+	readByte() (byte, error)
+}
+
+// portBurster is implemented by ports that can latch several bytes in one
+// transaction, which Opts.FastWrite uses.
+type portBurster interface {
+	writeBytes(b []byte) error
+}
+
+// i2cPort drives a PCF8574 style I²C expander. The expander has no
+// registers: every byte written is latched straight onto its pins, so writes
+// are plain one byte transactions rather than register writes.
+type i2cPort struct {
+	c *i2c.Dev
+}
+
+func (p *i2cPort) String() string {
+	return p.c.String()
+}
+
+func (p *i2cPort) writeByte(b byte) error {
+	return p.c.Tx([]byte{b}, nil)
+}
+
+func (p *i2cPort) writeBytes(b []byte) error {
+	return p.c.Tx(b, nil)
+}
+
+func (p *i2cPort) readByte() (byte, error) {
+	var buf [1]byte
+	if err := p.c.Tx(nil, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// spiPort drives a 74HC595 style shift register, latched on chip select.
+type spiPort struct {
+	c spi.Conn
+}
+
+func (p *spiPort) String() string {
+	return p.c.String()
+}
+
+func (p *spiPort) writeByte(b byte) error {
+	return p.c.Tx([]byte{b}, nil)
+}
+
+// gpioPort drives the LCD lines from GPIO pins, one pin per bit of the
+// port byte. Only the pins whose bit changed are set, the enable lines
+// last, so the other lines have settled before EN rises.
+type gpioPort struct {
+	pins  [8]gpio.PinIO // by port bit, nil where nothing is wired
+	en    byte          // enable bits
+	data  byte          // D4-D7 bits
+	rw    byte          // RW bit
+	last  byte
+	valid bool // last is what the pins hold
+}
+
+func (p *gpioPort) String() string {
+	return fmt.Sprintf("gpio(EN %s)", p.pins[bitIndex(p.en)])
+}
+
+// writeByte sets the pins to b. While b has RW high the data pins are
+// released as inputs, since the controller drives them then.
+func (p *gpioPort) writeByte(b byte) error {
+	changed := b ^ p.last
+	if changed&p.rw != 0 {
+		changed |= p.data
+	}
+	for _, enable := range []bool{false, true} {
+		for i, pin := range p.pins {
+			bit := byte(1) << i
+			if pin == nil || (p.en&bit != 0) != enable || p.valid && changed&bit == 0 {
+				continue
+			}
+			var err error
+			if b&p.rw != 0 && p.data&bit != 0 {
+				err = pin.In(gpio.PullUp, gpio.NoEdge)
+			} else {
+				err = pin.Out(gpio.Level(b&bit != 0))
+			}
+			if err != nil {
+				p.valid = false
+				return fmt.Errorf("%s: %w", pin, err)
+			}
+		}
+	}
+	p.last, p.valid = b, true
+	return nil
+}
+
+func (p *gpioPort) readByte() (byte, error) {
+	var v byte
+	for i, pin := range p.pins {
+		if pin != nil && pin.Read() == gpio.High {
+			v |= 1 << i
+		}
+	}
+	return v, nil
+}
+
+// bitIndex returns the number of the lowest bit set in mask.
+func bitIndex(mask byte) int {
+	for i := 0; i < 8; i++ {
+		if mask&(1<<i) != 0 {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Tim St. Pierre
+Progress bar for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"math"
+)
+
+// ProgressBar draws a bar across the full width of line showing fraction,
+// which is clamped to [0, 1]. Each cell is split into its five pixel
+// columns, so a 16 column line has 80 steps.
+//
+// The cursor is left where it was. The first call loads the partial fill
+// glyphs into CGRAM slots 0-4; those slots must not be reused while a bar is
+// on screen. It fails if any of them are held by anything else, CreateChar,
+// DefineChar or another built-in glyph helper; FreeChar them first.
+func (d *Dev) ProgressBar(line byte, fraction float64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var glyphs [][8]byte
+	for slot := byte(0); slot < 5; slot++ {
+		// slot n lights the n+1 leftmost pixel columns.
+		row := byte(0x1F) << (4 - slot) & 0x1F
+		glyphs = append(glyphs, [8]byte{row, row, row, row, row, row, row, row})
+	}
+	if err := d.reserve(cgramBar, 0, glyphs); err != nil {
+		return err
+	}
+	if math.IsNaN(fraction) || fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	cols := int(d.opts.Cols)
+	filled := int(math.Round(fraction * float64(cols*5)))
+	bar := bytes.Repeat([]byte{' '}, cols)
+	for i := range bar {
+		switch n := filled - i*5; {
+		case n >= 5:
+			bar[i] = 4
+		case n > 0:
+			bar[i] = byte(n - 1)
+		}
+	}
+	cur := d.saveCursor()
+	if err := d.setPosition(line, 0); err != nil {
+		return err
+	}
+	if _, err := d.writeData(bar); err != nil {
+		return err
+	}
+	return d.restoreCursor(cur)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 Tim St. Pierre
+Read path for lcd1602 character display (busy flag and address counter)
+*/
+package lcd1602
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// busyTimeout bounds how long wait polls the busy flag before giving up.
+const busyTimeout = 10 * time.Millisecond
+
+// busyPolls bounds the number of busy flag reads as well, for an Opts.Clock
+// whose Now does not move on by itself, as in tests.
+const busyPolls = 1000
+
+// ReadBusy reads the busy flag and the address counter from the controller.
+// It needs Opts.SupportsRead.
+//
+// The PCF8574 data lines are quasi-bidirectional, so the read drives D4-D7
+// high, raises RW with RS low and samples the port while EN is high, high
+// nibble first.
+func (d *Dev) ReadBusy() (bool, byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.readBusy()
+}
+
+func (d *Dev) readBusy() (bool, byte, error) {
+	hi, err := d.readNibble()
+	if err != nil {
+		return false, 0, err
+	}
+	lo, err := d.readNibble()
+	if err != nil {
+		return false, 0, err
+	}
+	v := hi<<4 | lo
+	return v&0x80 != 0, v & 0x7F, nil
+}
+
+// ReadAddress reads the controller's address counter and makes it the
+// driver's idea of the cursor, so positioning stays right after raw commands
+// the driver did not see. It needs Opts.SupportsRead.
+//
+// The counter reads back as a DDRAM address; see AddressToPosition.
+func (d *Dev) ReadAddress() (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, addr, err := d.readBusy()
+	if err != nil {
+		return 0, err
+	}
+	d.addr = addr
+	return addr, nil
+}
+
+// AddressToPosition returns the line (1 based) and column (0 based) that
+// show DDRAM address addr, allowing for the display shift. Addresses that are
+// not on screen give 0, 0.
+func (d *Dev) AddressToPosition(addr byte) (line, col byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	line, col, _ = d.positionOf(addr)
+	return line, col
+}
+
+// Ping checks that the display is alive without changing what it shows, for
+// a watchdog that Resets it when the check fails. With Opts.SupportsRead it
+// reads the busy flag, which must clear within a few milliseconds; a backpack
+// with nothing behind it reads as busy forever. Without, it resends the
+// display control command from the cached state, which only shows that the
+// backpack acknowledges writes. Failures wrap ErrNoResponse.
+func (d *Dev) Ping() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if d.opts.SupportsRead {
+		err = d.pollBusy()
+	} else {
+		err = d.writeDisplaySwitch()
+	}
+	if err != nil {
+		return fmt.Errorf("lcd1602: %w: %w", ErrNoResponse, err)
+	}
+	return nil
+}
+
+// wait holds off the next write after data byte c until the controller is
+// ready, either by polling the busy flag or by sleeping its execution time
+// plus CharDelay as a safety margin.
+func (d *Dev) wait(c byte) error {
+	if !d.opts.UseBusyFlag {
+		d.opts.clock().Sleep(d.delayFor(c, true) + d.opts.CharDelay)
+		return nil
+	}
+	if d.all && d.dual() {
+		// Both controllers would drive the data lines at once, so poll
+		// them in turn.
+		ctrl := d.ctrl
+		d.all = false
+		defer func() { d.all, d.ctrl = true, ctrl }()
+		for d.ctrl = 0; d.ctrl < 2; d.ctrl++ {
+			if err := d.pollBusy(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return d.pollBusy()
+}
+
+// pollBusy reads the busy flag until it clears, busyTimeout passes or it
+// has been read busyPolls times.
+func (d *Dev) pollBusy() error {
+	deadline := d.opts.clock().Now().Add(busyTimeout)
+	for polls := 1; ; polls++ {
+		busy, _, err := d.readBusy()
+		if err != nil {
+			return err
+		}
+		if !busy {
+			return nil
+		}
+		if polls >= busyPolls || d.opts.clock().Now().After(deadline) {
+			return errors.New("lcd1602: timed out waiting for busy flag")
+		}
+	}
+}
+
+// readNibble pulses EN with RW high and returns D4-D7 as the low four bits.
+//
+// Writing 1s to the PCF8574 data pins turns them into weak pull-ups, which
+// lets the controller drive them while EN is high.
+func (d *Dev) readNibble() (byte, error) {
+	if !d.opts.SupportsRead {
+		return 0, fmt.Errorf("lcd1602: %w: reads need Opts.SupportsRead", ErrUnsupported)
+	}
+	var data byte
+	data = pinInterpret(d.pins.D4, data, true)
+	data = pinInterpret(d.pins.D5, data, true)
+	data = pinInterpret(d.pins.D6, data, true)
+	data = pinInterpret(d.pins.D7, data, true)
+	data = pinInterpret(d.pins.RW, data, true)
+	data = d.backlightBit(data, d.backlight_state)
+	if err := d.writePort(data); err != nil {
+		return 0, err
+	}
+	if err := d.writePort(data | d.enBits()); err != nil {
+		return 0, err
+	}
+	d.opts.clock().Sleep(d.opts.enablePulseWidth())
+	r, ok := d.p.(portReader)
+	if !ok {
+		return 0, fmt.Errorf("lcd1602: %w: %s cannot be read back", ErrUnsupported, d.p)
+	}
+	v, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if err := d.writePort(data); err != nil {
+		return 0, err
+	}
+	var nibble byte
+	for i, pin := range []byte{d.pins.D4, d.pins.D5, d.pins.D6, d.pins.D7} {
+		if v&(1<<pin) != 0 {
+			nibble |= 1 << i
+		}
+	}
+	return nibble, nil
+}
+
+// WriteRawPort latches b straight onto the expander's eight pins, bypassing
+// the LCD protocol, for whatever else is wired to a clone backpack's pins.
+//
+// USE AT YOUR OWN RISK. The bits are driven exactly as given: a byte with
+// the EN bit set clocks garbage into the controller, and the backlight
+// follows its bit regardless of SetBacklight. The driver's idea of the
+// display can then be wrong; Reset the display if it was used mid-session.
+func (d *Dev) WriteRawPort(b byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writePort(b)
+}
+
+// ReadRawPort samples the expander's eight pins. On a PCF8574 a pin only
+// reads as an input while it was last written high. It needs a transport
+// that can be read, but not Opts.SupportsRead.
+func (d *Dev) ReadRawPort() (byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkOpen(); err != nil {
+		return 0, err
+	}
+	r, ok := d.p.(portReader)
+	if !ok {
+		return 0, fmt.Errorf("lcd1602: %w: %s cannot be read back", ErrUnsupported, d.p)
+	}
+	return r.readByte()
+}
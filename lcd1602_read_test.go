@@ -0,0 +1,44 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 reads
+*/
+package lcd1602
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// stuck is a recorder whose lines always read back as busy.
+type stuck struct {
+	recorder
+	reads int
+}
+
+func (s *stuck) readByte() (byte, error) {
+	s.reads++
+	return 0xFF, nil
+}
+
+// stoppedClock never moves on, not even when slept on.
+type stoppedClock struct{}
+
+func (stoppedClock) Now() time.Time        { return time.Unix(0, 0) }
+func (stoppedClock) Sleep(d time.Duration) {}
+
+func TestPollBusyBounded(t *testing.T) {
+	o := DefaultOpts
+	o.SupportsRead = true
+	p := &stuck{}
+	d, _ := newTestDevOn(t, p, o)
+	d.opts.Clock = stoppedClock{}
+	p.reads = 0
+	if err := d.Ping(); !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("Ping on a display stuck busy = %v, want ErrNoResponse", err)
+	}
+	// Two nibbles per read of the busy flag.
+	if p.reads != 2*busyPolls {
+		t.Errorf("%d reads, want %d", p.reads, 2*busyPolls)
+	}
+}
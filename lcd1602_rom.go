@@ -0,0 +1,70 @@
+/*
+Copyright 2024 Tim St. Pierre
+Character ROM tables for lcd1602 character display
+*/
+package lcd1602
+
+// A00Rom gives the glyph each character code shows on the A00 (Japanese)
+// ROM. Codes 0x00-0x07 are the CGRAM glyphs; they and codes without a clear
+// Unicode equivalent, such as the descender forms of g, j, p, q and y, are 0.
+// 0xA1-0xDF are the half-width katakana; 0xDF doubles as the degree sign.
+var A00Rom = [256]rune{
+	0x20: ' ', '!', '"', '#', '$', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	0x30: '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	0x40: '@', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	0x50: 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '[', '¥', ']', '^', '_',
+	0x60: '`', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	0x70: 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', '{', '|', '}', '→', '←',
+	0xA1: '｡', '｢', '｣', '､', '･', 'ｦ', 'ｧ', 'ｨ', 'ｩ', 'ｪ', 'ｫ', 'ｬ', 'ｭ', 'ｮ', 'ｯ',
+	0xB0: 'ｰ', 'ｱ', 'ｲ', 'ｳ', 'ｴ', 'ｵ', 'ｶ', 'ｷ', 'ｸ', 'ｹ', 'ｺ', 'ｻ', 'ｼ', 'ｽ', 'ｾ', 'ｿ',
+	0xC0: 'ﾀ', 'ﾁ', 'ﾂ', 'ﾃ', 'ﾄ', 'ﾅ', 'ﾆ', 'ﾇ', 'ﾈ', 'ﾉ', 'ﾊ', 'ﾋ', 'ﾌ', 'ﾍ', 'ﾎ', 'ﾏ',
+	0xD0: 'ﾐ', 'ﾑ', 'ﾒ', 'ﾓ', 'ﾔ', 'ﾕ', 'ﾖ', 'ﾗ', 'ﾘ', 'ﾙ', 'ﾚ', 'ﾛ', 'ﾜ', 'ﾝ', 'ﾞ', 'ﾟ',
+	0xE0: 'α', 'ä', 'β', 'ε', 'μ', 'σ', 'ρ', 0, '√', 0, 0, 'ˣ', '¢', '£', 'ñ', 'ö',
+	0xF2: 'θ', '∞', 'Ω', 'ü', 'Σ', 'π', 0, 0, '千', '万', '円', '÷', 0, '█',
+}
+
+// A02Rom gives the glyph each character code shows on the A02 (European)
+// ROM: symbols and arrows below 0x20, ASCII with a house at 0x7F, Cyrillic
+// capitals and Greek letters in 0x80-0x9F, and Latin-1 from 0xA1 up. Codes
+// without a clear Unicode equivalent are 0.
+var A02Rom = [256]rune{
+	0x10: '▶', '◀', '“', '”', 0, 0, '●', '↲', '↑', '↓', '→', '←', '≤', '≥', '▲', '▼',
+	0x20: ' ', '!', '"', '#', '$', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	0x30: '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	0x40: '@', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	0x50: 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '[', '\\', ']', '^', '_',
+	0x60: '`', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	0x70: 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', '{', '|', '}', '~', '⌂',
+	0x80: 'Б', 'Д', 'Ж', 'З', 'И', 'Й', 'Л', 'П', 'У', 'Ц', 'Ч', 'Ш', 'Щ', 'Ъ', 'Ы', 'Э',
+	0x90: 'α', '♪', 'Γ', 'π', 'Σ', 'σ', '♬', 'τ', 0, 'Θ', 'Ω', 'δ', '∞', '♥', 'ε', '∩',
+	0xA1: '¡', '¢', '£', '¤', '¥', '¦', '§', '¨', '©', 'ª', '«', '¬', '­', '®', '¯',
+	0xB0: '°', '±', '²', '³', '´', 'µ', '¶', '·', '¸', '¹', 'º', '»', '¼', '½', '¾', '¿',
+	0xC0: 'À', 'Á', 'Â', 'Ã', 'Ä', 'Å', 'Æ', 'Ç', 'È', 'É', 'Ê', 'Ë', 'Ì', 'Í', 'Î', 'Ï',
+	0xD0: 'Ð', 'Ñ', 'Ò', 'Ó', 'Ô', 'Õ', 'Ö', '×', 'Ø', 'Ù', 'Ú', 'Û', 'Ü', 'Ý', 'Þ', 'ß',
+	0xE0: 'à', 'á', 'â', 'ã', 'ä', 'å', 'æ', 'ç', 'è', 'é', 'ê', 'ë', 'ì', 'í', 'î', 'ï',
+	0xF0: 'ð', 'ñ', 'ò', 'ó', 'ô', 'õ', 'ö', '÷', 'ø', 'ù', 'ú', 'û', 'ü', 'ý', 'þ', 'ÿ',
+}
+
+// RuneToByte returns the character code that shows r in rom, if any.
+func RuneToByte(r rune, rom [256]rune) (byte, bool) {
+	if r == 0 {
+		return 0, false
+	}
+	for code, glyph := range rom {
+		if glyph == r {
+			return byte(code), true
+		}
+	}
+	return 0, false
+}
+
+// romTable returns the table for v, or nil when the ROM is unknown.
+func romTable(v RomVariant) *[256]rune {
+	switch v {
+	case RomA00:
+		return &A00Rom
+	case RomA02:
+		return &A02Rom
+	}
+	return nil
+}
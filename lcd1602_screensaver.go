@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Tim St. Pierre
+Idle screensaver for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// screensaverStep is the time between shifts with ScreensaverShiftDisplay.
+const screensaverStep = 2 * time.Second
+
+// ScreensaverAction is what a Screensaver does once the display is idle.
+type ScreensaverAction uint8
+
+const (
+	// Switch the backlight off. A running SetBrightness PWM is stopped and
+	// the backlight comes back fully on.
+	ScreensaverBlankBacklight ScreensaverAction = iota
+	// Shift the whole display one cell left every couple of seconds, so no
+	// pixel stays lit for long.
+	ScreensaverShiftDisplay
+	// Switch the display off. The controller keeps its contents, so they
+	// reappear unchanged on waking.
+	ScreensaverDisplayOff
+)
+
+// ScreensaverHandle runs a Screensaver until stopped. Its state is guarded
+// by d.mu.
+type ScreensaverHandle struct {
+	d       *Dev
+	timeout time.Duration
+	action  ScreensaverAction
+	last    time.Time // last write
+	asleep  bool
+	light   bool // backlight before ScreensaverBlankBacklight
+	shifted byte // cells shifted left by ScreensaverShiftDisplay
+	stop    func()
+	once    sync.Once
+}
+
+// Screensaver guards against ghosting on always-on panels: once nothing has
+// been written for timeout, action is applied, and the next write, SetPosition
+// or Clear wakes the display, putting the backlight, display or shift back
+// first. Background writers such as Spinner or ScrollRegion count as writes,
+// so they keep the display awake. Starting a Screensaver stops one already
+// running on d.
+func (d *Dev) Screensaver(timeout time.Duration, action ScreensaverAction) *ScreensaverHandle {
+	d.mu.Lock()
+	prior := d.saver
+	d.mu.Unlock()
+	if prior != nil {
+		prior.Stop()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := &ScreensaverHandle{d: d, timeout: timeout, action: action, last: time.Now()}
+	d.saver = s
+	s.stop = d.runBackground(func(ctx context.Context) error {
+		for {
+			d.mu.Lock()
+			wait := time.Until(s.last.Add(s.timeout))
+			if s.asleep {
+				wait = screensaverStep
+			}
+			d.mu.Unlock()
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+			d.mu.Lock()
+			err := d.idle(s)
+			d.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	})
+	return s
+}
+
+// idle puts the display to sleep once s has timed out, and moves a shifting
+// one on. Callers must hold d.mu.
+func (d *Dev) idle(s *ScreensaverHandle) error {
+	switch {
+	case s.asleep:
+		if s.action != ScreensaverShiftDisplay {
+			return nil
+		}
+	case time.Since(s.last) < s.timeout:
+		return nil
+	}
+	if !s.asleep {
+		d.debug("screensaver sleep", "action", s.action)
+	}
+	switch s.action {
+	case ScreensaverBlankBacklight:
+		s.light = d.backlight_state
+		d.stopPWM()
+		if err := d.setBacklight(false); err != nil {
+			return err
+		}
+	case ScreensaverShiftDisplay:
+		if err := d.shiftDisplay(false); err != nil {
+			return err
+		}
+		s.shifted = (s.shifted + 1) % ddramLineLen
+	case ScreensaverDisplayOff:
+		option := byte(CMD_Display_Control)
+		if err := d.both(func() error { return d.command(option) }); err != nil {
+			return err
+		}
+	}
+	s.asleep = true
+	return nil
+}
+
+// touch records a write for the screensaver, waking the display first if it
+// is asleep. Callers must hold d.mu.
+func (d *Dev) touch() error {
+	s := d.saver
+	if s == nil {
+		return nil
+	}
+	s.last = time.Now()
+	if !s.asleep {
+		return nil
+	}
+	d.debug("screensaver wake")
+	s.asleep = false
+	switch s.action {
+	case ScreensaverBlankBacklight:
+		return d.setBacklight(s.light)
+	case ScreensaverShiftDisplay:
+		// Shift back the shorter way round the 40 cell line.
+		for s.shifted != 0 {
+			right := s.shifted <= ddramLineLen/2
+			if err := d.shiftDisplay(right); err != nil {
+				return err
+			}
+			if right {
+				s.shifted--
+			} else {
+				s.shifted = (s.shifted + 1) % ddramLineLen
+			}
+		}
+	case ScreensaverDisplayOff:
+		return d.writeDisplaySwitch()
+	}
+	return nil
+}
+
+// Stop halts the screensaver and wakes the display if it is asleep. Later
+// calls do nothing.
+func (s *ScreensaverHandle) Stop() error {
+	var err error
+	s.once.Do(func() {
+		s.stop()
+		d := s.d
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.saver == s {
+			err = d.touch()
+			d.saver = nil
+		}
+	})
+	return err
+}
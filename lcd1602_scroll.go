@@ -0,0 +1,253 @@
+/*
+Copyright 2024 Tim St. Pierre
+Scrolling text for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultScrollGap = 4
+
+// ScrollText shows text on line as a marquee, moving it one cell left every
+// step until ctx is cancelled. The text repeats with Opts.ScrollGap blank
+// cells between the end and the next start.
+//
+// Only cells that change between frames are rewritten. When ctx is done the
+// line is put back to the start of the text. Text that fits on the line is
+// written once and left static. ScrollText blocks; run it in its own
+// goroutine and cancel ctx to stop it. A step of zero or less fails at once
+// with an error wrapping ErrBadInterval. The cursor is put back after each
+// frame, so writes elsewhere between frames land where they were aimed.
+func (d *Dev) ScrollText(line byte, text string, step time.Duration, ctx context.Context) error {
+	if err := checkInterval(step); err != nil {
+		return err
+	}
+	_, cols := d.Dimensions()
+	return d.scrollWindow(ctx, line, 0, cols, text, step)
+}
+
+// ScrollRegion is ScrollText confined to the width cells of line from
+// startCol, so a fixed label can sit beside a scrolling value. width is
+// clipped to the line. Only the window's cells are ever rewritten; text that
+// fits the window is written once and left static.
+//
+// The scrolling runs in the background until the returned stop is called,
+// which puts the window back to the start of the text and may be called more
+// than once. A bad position or bus error ends it early and goes to
+// Opts.OnError. So does an interval of zero or less, in which case nothing
+// is started.
+func (d *Dev) ScrollRegion(line, startCol, width byte, text string, interval time.Duration) (stop func()) {
+	if err := checkInterval(interval); err != nil {
+		d.backgroundError(err)
+		return func() {}
+	}
+	if _, cols := d.Dimensions(); startCol < cols && width > cols-startCol {
+		width = cols - startCol
+	}
+	return d.runBackground(func(ctx context.Context) error {
+		return d.scrollWindow(ctx, line, startCol, width, text, interval)
+	})
+}
+
+// scrollWindow runs a marquee of text in the width cells of line from col
+// until ctx is done.
+func (d *Dev) scrollWindow(ctx context.Context, line, col, width byte, text string, step time.Duration) error {
+	cols := int(width)
+	d.mu.Lock()
+	loop := make([]byte, 0, len(text))
+	for _, r := range text {
+		loop = append(loop, d.mapRune(r))
+	}
+	d.mu.Unlock()
+	if len(loop) > cols {
+		gap := int(d.opts.ScrollGap)
+		if gap == 0 {
+			gap = defaultScrollGap
+		}
+		loop = append(loop, bytes.Repeat([]byte{' '}, gap)...)
+	}
+	frame := func(offset int) []byte {
+		out := bytes.Repeat([]byte{' '}, cols)
+		if len(loop) <= cols {
+			copy(out, loop)
+			return out
+		}
+		for i := range out {
+			out[i] = loop[(offset+i)%len(loop)]
+		}
+		return out
+	}
+	// draw puts the cursor back afterwards, so that the caller's own writes
+	// between ticks do not land in the window.
+	draw := func(prev, next []byte) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		cur := d.saveCursor()
+		if err := d.writeDiff(line, col, prev, next); err != nil {
+			return err
+		}
+		return d.restoreCursor(cur)
+	}
+
+	shown := frame(0)
+	if err := draw(nil, shown); err != nil {
+		return err
+	}
+	if len(loop) <= cols {
+		<-ctx.Done()
+		return nil
+	}
+	err := RunAnimation(ctx, &animation{interval: step, frame: func(n int) error {
+		if n == 0 {
+			// Already drawn above, before the static check.
+			return nil
+		}
+		next := frame(n % len(loop))
+		if err := draw(shown, next); err != nil {
+			return err
+		}
+		shown = next
+		return nil
+	}})
+	if err != nil && err == ctx.Err() {
+		return draw(shown, frame(0))
+	}
+	return err
+}
+
+// DefaultScrollInterval is the tick a ScrollController starts with.
+const DefaultScrollInterval = 300 * time.Millisecond
+
+// ScrollController scrolls one line in the background while the other lines
+// stay put.
+//
+// The controller's own display shift (DisplayShift) cannot do this: it moves
+// the visible window over DDRAM for all lines at once, so every line scrolls
+// together. ScrollController instead rewrites the cells of its one line on
+// each tick, see ScrollText.
+type ScrollController struct {
+	// Time between one-cell steps. Changes take effect on the next Start.
+	// Zero or less makes the scroll stop at once with an error wrapping
+	// ErrBadInterval, which Stop returns and Opts.OnError receives.
+	Interval time.Duration
+
+	d      *Dev
+	line   byte
+	text   string
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewScrollController returns a stopped controller that scrolls text on line.
+func NewScrollController(d *Dev, line byte, text string) *ScrollController {
+	return &ScrollController{Interval: DefaultScrollInterval, d: d, line: line, text: text}
+}
+
+// Start begins scrolling. It does nothing if already running, but starts
+// again after a scroll that ended early on an error.
+func (s *ScrollController) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		select {
+		case <-s.done:
+			s.cancel()
+		default:
+			return
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cancel, s.done, s.err = cancel, done, nil
+	interval := s.Interval
+	untrack := s.d.track(func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		err := s.d.ScrollText(s.line, s.text, interval, ctx)
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		// As in runBackground, OnError runs last so that it may call Stop,
+		// Halt or Close.
+		untrack()
+		close(done)
+		if err != nil {
+			s.d.backgroundError(err)
+		}
+	}()
+}
+
+// Stop halts scrolling, waits for the line to be restored and returns the
+// bus error that ended the scroll early, if any.
+func (s *ScrollController) Stop() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// AutoShiftOpts adjusts AutoShiftWith.
+type AutoShiftOpts struct {
+	// Put the display back with Home when stop is called, so the text sits
+	// where it was before the shifting began. The cursor goes to the top
+	// left too.
+	Home bool
+}
+
+// AutoShift moves the whole display one step every interval using the
+// controller's display shift, see DisplayShift. All lines move together.
+//
+// The returned stop halts the shifting and waits for the goroutine to exit;
+// it may be called more than once. The display is left where it stopped;
+// use AutoShiftWith and AutoShiftOpts.Home to have stop put it back. A bus
+// error ends the shifting early. Errors go to Opts.OnError, including an
+// interval of zero or less, for which nothing is started.
+func (d *Dev) AutoShift(right bool, interval time.Duration) (stop func()) {
+	return d.AutoShiftWith(right, interval, AutoShiftOpts{})
+}
+
+// AutoShiftWith is AutoShift with options. An error from the Home done by
+// stop goes to Opts.OnError. Halt and Close end the shifting without it.
+func (d *Dev) AutoShiftWith(right bool, interval time.Duration, o AutoShiftOpts) (stop func()) {
+	if err := checkInterval(interval); err != nil {
+		d.backgroundError(err)
+		return func() {}
+	}
+	halt := d.runBackground(func(ctx context.Context) error {
+		return RunAnimation(ctx, &animation{interval: interval, frame: func(n int) error {
+			if n == 0 {
+				return nil
+			}
+			return d.DisplayShift(right)
+		}})
+	})
+	if !o.Home {
+		return halt
+	}
+	var once sync.Once
+	return func() {
+		halt()
+		once.Do(func() {
+			if err := d.Home(); err != nil {
+				d.backgroundError(err)
+			}
+		})
+	}
+}
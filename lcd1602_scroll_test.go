@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 scrolling
+*/
+package lcd1602
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBadInterval(t *testing.T) {
+	if err := RunAnimation(context.Background(), &animation{interval: 0}); !errors.Is(err, ErrBadInterval) {
+		t.Errorf("RunAnimation with no interval = %v, want ErrBadInterval", err)
+	}
+	var mu sync.Mutex
+	var errs []error
+	o := DefaultOpts
+	o.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	d, r, _ := newReadyDev(t, o)
+	d.AutoShift(true, 0)()
+	d.ScrollRegion(1, 0, 8, "longer than the window", -time.Second)()
+	if err := d.ScrollText(1, "longer than the whole line", 0, context.Background()); !errors.Is(err, ErrBadInterval) {
+		t.Errorf("ScrollText with no interval = %v, want ErrBadInterval", err)
+	}
+	s := NewScrollController(d, 1, "longer than the whole line")
+	s.Interval = 0
+	s.Start()
+	if err := s.Stop(); !errors.Is(err, ErrBadInterval) {
+		t.Errorf("ScrollController with no interval stopped with %v, want ErrBadInterval", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 3 {
+		t.Fatalf("OnError got %v, want 3 errors", errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrBadInterval) {
+			t.Errorf("OnError got %v, want ErrBadInterval", err)
+		}
+	}
+	if raw := r.take(); len(raw) != 0 {
+		t.Errorf("wrote %x", raw)
+	}
+}
+
+func TestAutoShiftHome(t *testing.T) {
+	for _, home := range []bool{false, true} {
+		d, _, _ := newReadyDev(t, DefaultOpts)
+		stop := d.AutoShiftWith(false, time.Millisecond, AutoShiftOpts{Home: home})
+		deadline := time.Now().Add(5 * time.Second)
+		for d.VisibleOffset() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("display never shifted")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		stop()
+		stop()
+		if shifted := d.VisibleOffset() != 0; shifted == home {
+			t.Errorf("Home %v: display offset %d after stop", home, d.VisibleOffset())
+		}
+	}
+}
+
+func TestScrollKeepsCursor(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	if err := d.SetPosition(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	stop := d.ScrollRegion(1, 0, 8, "a marquee longer than eight", time.Millisecond)
+	// Wait for a tick past the first frame, so that the cursor has been
+	// into the window more than once.
+	const first = "a marque"
+	window := func() string { return d.Snapshot()[:8] }
+	deadline := time.Now().Add(5 * time.Second)
+	for w := window(); w == first || w == "        "; w = window() {
+		if time.Now().After(deadline) {
+			t.Fatal("window never scrolled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := d.WriteString("XY"); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	want := first + "        \nXY              "
+	if got := d.Snapshot(); got != want {
+		t.Errorf("screen\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestScrollMapsRunes(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	// Five bytes but four cells: it fits the window and does not scroll.
+	stop := d.ScrollRegion(1, 0, 4, "20°C", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	var data []byte
+	for _, o := range latched(t, d, r.take()) {
+		if o.data {
+			data = append(data, o.b)
+		}
+	}
+	if want := []byte{'2', '0', 0xDF, 'C'}; !slices.Equal(data, want) {
+		t.Errorf("wrote %x, want %x", data, want)
+	}
+}
+
+func TestScrollControllerRestart(t *testing.T) {
+	o := DefaultOpts
+	o.OnError = func(error) {}
+	d, _, _ := newReadyDev(t, o)
+	s := NewScrollController(d, 1, "a marquee longer than the line")
+	s.Interval = 0
+	s.Start()
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	<-done
+	// The failed scroll has ended by itself; Start runs it again.
+	s.Interval = time.Millisecond
+	s.Start()
+	const first = "a marquee longer"
+	deadline := time.Now().Add(5 * time.Second)
+	for w := d.Snapshot()[:16]; w == first || w == "                "; w = d.Snapshot()[:16] {
+		if time.Now().After(deadline) {
+			t.Fatal("second Start did not scroll")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("Stop = %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024 Tim St. Pierre
+Diagnostics for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	selfTestPause  = 500 * time.Millisecond // hold for each full screen pattern
+	selfTestStep   = 30 * time.Millisecond  // hold for each cell of the walk
+	byteTablePause = 3 * time.Second        // hold for each ShowByteTable page
+)
+
+// checkerGlyph lights alternate pixels, which shows up dead rows or columns
+// in a CGRAM glyph.
+var checkerGlyph = [8]byte{0x15, 0x0A, 0x15, 0x0A, 0x15, 0x0A, 0x15, 0x0A}
+
+// SelfTest runs a visual check of a freshly assembled unit: every pixel on,
+// a block walked across every cell, the backlight blinked, and a
+// checkerboard glyph loaded into CGRAM and shown across the screen. It takes
+// a few seconds and holds the display throughout.
+//
+// The first bus error stops the test and is returned. Either way the screen
+// ends blank with the backlight as it was before. The CGRAM glyph uses a
+// free slot, which is released again, so SelfTest fails if all slots are
+// taken.
+func (d *Dev) SelfTest() (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopPWM()
+	prior := d.backlight_state
+	defer func() {
+		if e := d.clear(); err == nil {
+			err = e
+		}
+		if e := d.setBacklight(prior); err == nil {
+			err = e
+		}
+	}()
+	if err := d.setBacklight(true); err != nil {
+		return err
+	}
+	if err := d.selfTestFill(0xFF); err != nil {
+		return err
+	}
+	if err := d.clear(); err != nil {
+		return err
+	}
+	for line := byte(1); line <= d.opts.Lines; line++ {
+		for col := byte(0); col < d.opts.Cols; col++ {
+			if err := d.setPosition(line, col); err != nil {
+				return err
+			}
+			if _, err := d.writeData([]byte{0xFF}); err != nil {
+				return err
+			}
+			d.opts.clock().Sleep(selfTestStep)
+			if err := d.setPosition(line, col); err != nil {
+				return err
+			}
+			if _, err := d.writeData([]byte{' '}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, on := range []bool{false, true} {
+		if err := d.setBacklight(on); err != nil {
+			return err
+		}
+		d.opts.clock().Sleep(selfTestPause)
+	}
+	slot, err := d.defineChar(checkerGlyph, cgramUser)
+	if err != nil {
+		return err
+	}
+	defer func() { d.cgram[slot] = cgramFree }()
+	return d.selfTestFill(slot)
+}
+
+// selfTestFill writes c to every cell and holds it for selfTestPause.
+func (d *Dev) selfTestFill(c byte) error {
+	for line := byte(1); line <= d.opts.Lines; line++ {
+		if err := d.setPosition(line, 0); err != nil {
+			return err
+		}
+		if _, err := d.writeData(bytes.Repeat([]byte{c}, int(d.opts.Cols))); err != nil {
+			return err
+		}
+	}
+	d.opts.clock().Sleep(selfTestPause)
+	return nil
+}
+
+// ShowByteTable writes count character codes from start on as raw data, a
+// run per line, so the glyphs of a part's ROM can be photographed and
+// matched against A00Rom or A02Rom. On displays at least 8 columns wide each
+// line starts with the hex code of its first glyph. When the codes do not
+// fit on one screen they are shown a page at a time, each held for a few
+// seconds; the last page stays up. The table stops at 0xFF.
+//
+// Codes 0x00-0x0F show the CGRAM glyphs as currently loaded, 0x08-0x0F
+// repeating 0x00-0x07.
+func (d *Dev) ShowByteTable(start, count byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	width, label := int(d.opts.Cols), d.opts.Cols >= 8
+	if label {
+		width -= 3
+	}
+	next, left := int(start), int(count)
+	if left > 0x100-next {
+		left = 0x100 - next
+	}
+	for first := true; left > 0; first = false {
+		if !first {
+			d.opts.clock().Sleep(byteTablePause)
+		}
+		if err := d.clear(); err != nil {
+			return err
+		}
+		for line := byte(1); line <= d.opts.Lines && left > 0; line++ {
+			if err := d.setPosition(line, 0); err != nil {
+				return err
+			}
+			var run []byte
+			if label {
+				run = fmt.Appendf(run, "%02X ", next)
+			}
+			for i := 0; i < width && left > 0; i++ {
+				run = append(run, byte(next))
+				next, left = next+1, left-1
+			}
+			if _, err := d.writeData(run); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Benchmark measures the character rate the current options achieve on
+// this bus: it writes chars characters across line 1 and times the data
+// writes, including CharDelay or the busy flag polls after each, but not the
+// positioning commands between lines. Line 1 and the cursor are put back
+// afterwards. Compare the rate with FastWrite, UseBusyFlag or a shorter
+// CharDelay to see whether they are worth it.
+func (d *Dev) Benchmark(chars int) (charsPerSec float64, err error) {
+	if chars < 1 {
+		return 0, fmt.Errorf("lcd1602: Benchmark needs at least 1 character, got %d", chars)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batch != nil {
+		return 0, errors.New("lcd1602: Benchmark cannot run during a batch")
+	}
+	cur := d.saveCursor()
+	line := append([]byte(nil), d.shown[0]...)
+	defer func() {
+		// The benchmark bypassed the screen copy, so every cell is resent.
+		if e := d.writeDiff(1, 0, nil, line); err == nil {
+			err = e
+		}
+		if e := d.restoreCursor(cur); err == nil {
+			err = e
+		}
+	}()
+	var spent time.Duration
+	for n := 0; n < chars; {
+		if err := d.setPosition(1, 0); err != nil {
+			return 0, err
+		}
+		start := d.opts.clock().Now()
+		for col := 0; col < int(d.opts.Cols) && n < chars; col, n = col+1, n+1 {
+			c := byte('0' + n%10)
+			if err := d.write(c, false); err != nil {
+				return 0, err
+			}
+			if err := d.wait(c); err != nil {
+				return 0, err
+			}
+		}
+		spent += d.opts.clock().Now().Sub(start)
+	}
+	if spent <= 0 {
+		// An Opts.Clock that does not move on while sleeping.
+		return 0, errors.New("lcd1602: Benchmark measured no elapsed time")
+	}
+	return float64(chars) / spent.Seconds(), nil
+}
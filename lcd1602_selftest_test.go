@@ -0,0 +1,18 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 diagnostics
+*/
+package lcd1602
+
+import "testing"
+
+func TestBenchmarkStoppedClock(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	if rate, err := d.Benchmark(16); err != nil || rate <= 0 {
+		t.Errorf("Benchmark = %v, %v, want a positive rate", rate, err)
+	}
+	d.opts.Clock = stoppedClock{}
+	if rate, err := d.Benchmark(16); err == nil {
+		t.Errorf("Benchmark on a stopped clock = %v, want an error", rate)
+	}
+}
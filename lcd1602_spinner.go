@@ -0,0 +1,110 @@
+/*
+Copyright 2024 Tim St. Pierre
+Busy spinner for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// spinnerInterval is the time each spinner frame is shown.
+const spinnerInterval = 150 * time.Millisecond
+
+// spinnerFrames are the positions of a bar turning clockwise. They live in
+// CGRAM because the A00 ROM shows ¥ for a backslash.
+var spinnerFrames = [][8]byte{
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x00}, // |
+	{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00, 0x00}, // /
+	{0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x00}, // -
+	{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00, 0x00}, // \
+}
+
+// SpinnerHandle animates a Spinner until stopped.
+type SpinnerHandle struct {
+	d         *Dev
+	line, col byte
+	stop      func()
+	once      sync.Once
+}
+
+// Spinner shows a turning bar in the single cell at col on line, for work
+// whose progress cannot be measured; see ProgressBar for work that can.
+//
+// The frames take four free CGRAM slots, shared by all spinners on d. Each
+// tick rewrites only the one cell and puts the cursor back where it was, so
+// other writes can carry on around it.
+func (d *Dev) Spinner(line, col byte) (*SpinnerHandle, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	slots, err := d.spinnerSlots()
+	if err != nil {
+		return nil, err
+	}
+	s := &SpinnerHandle{d: d, line: line, col: col}
+	if err := s.draw(slots[0]); err != nil {
+		return nil, err
+	}
+	s.stop = d.runBackground(func(ctx context.Context) error {
+		return RunAnimation(ctx, &animation{interval: spinnerInterval, frame: func(n int) error {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return s.draw(slots[n%len(slots)])
+		}})
+	})
+	return s, nil
+}
+
+// spinnerSlots returns the CGRAM slots holding the spinner frames,
+// loading them if need be.
+func (d *Dev) spinnerSlots() ([]byte, error) {
+	var slots []byte
+	for slot, o := range d.cgram {
+		if o == cgramSpinner {
+			slots = append(slots, byte(slot))
+		}
+	}
+	if len(slots) == len(spinnerFrames) {
+		return slots, nil
+	}
+	for _, slot := range slots {
+		d.cgram[slot] = cgramFree
+	}
+	slots = slots[:0]
+	for _, frame := range spinnerFrames {
+		slot, err := d.defineChar(frame, cgramSpinner)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// draw writes c in the spinner cell and puts the cursor back. Callers must
+// hold s.d.mu.
+func (s *SpinnerHandle) draw(c byte) error {
+	d := s.d
+	cur := d.saveCursor()
+	if err := d.setPosition(s.line, s.col); err != nil {
+		return err
+	}
+	if _, err := d.writeData([]byte{c}); err != nil {
+		return err
+	}
+	return d.restoreCursor(cur)
+}
+
+// Stop halts the spinner and blanks its cell. Later calls do nothing.
+func (s *SpinnerHandle) Stop() error {
+	var err error
+	s.once.Do(func() {
+		s.stop()
+		s.d.mu.Lock()
+		defer s.d.mu.Unlock()
+		err = s.draw(' ')
+	})
+	return err
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024 Tim St. Pierre
+Temperature readout for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"strconv"
+)
+
+// degreeGlyph is a small raised ring.
+var degreeGlyph = [8]byte{0x06, 0x09, 0x09, 0x06, 0x00, 0x00, 0x00, 0x00}
+
+// WriteTemperature writes value with one decimal at col on line, followed by
+// a degree sign and unit, e.g. "21.5°C". Overflow is handled as for
+// WriteString.
+//
+// The A00 and A02 ROMs have a degree glyph built in. With RomOther the glyph
+// is loaded into a free CGRAM slot on first use.
+func (d *Dev) WriteTemperature(line, col byte, value float64, unit rune) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var degree byte
+	switch d.opts.RomVariant {
+	case RomA00:
+		degree = 0xDF
+	case RomA02:
+		degree = 0xB0
+	default:
+		slot, ok := d.slotOf(cgramDegree)
+		if !ok {
+			var err error
+			if slot, err = d.defineChar(degreeGlyph, cgramDegree); err != nil {
+				return err
+			}
+		}
+		degree = slot
+	}
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	// The ring goes through the same overflow handling as the digits and
+	// unit, so a reading near the line end is cut off as a whole.
+	reading := []rune(strconv.FormatFloat(value, 'f', 1, 64) + "°" + string(unit))
+	_, err := d.typeCodes(reading, func(r rune) byte {
+		if r == '°' {
+			return degree
+		}
+		return d.mapRune(r)
+	}, nil)
+	return err
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 temperature readout
+*/
+package lcd1602
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWriteTemperatureDegree(t *testing.T) {
+	reading := func(degree byte) []op {
+		ops := append([]op{cmd(CMD_DDRAM_Set | 0x42)}, text("21.5")...)
+		return append(ops, op{data: true, b: degree}, op{data: true, b: 'C'})
+	}
+	// Without a known ROM the ring goes into the first free slot, and the
+	// cursor is put back, before the reading is written.
+	load := append([]op{cmd(CMD_CGRAM_Set | 0<<3)}, rows(degreeGlyph)...)
+	load = append(load, cmd(CMD_DDRAM_Set|0x00))
+	tests := []struct {
+		name   string
+		rom    RomVariant
+		load   []op
+		degree byte
+	}{
+		{"A00", RomA00, nil, 0xDF},
+		{"A02", RomA02, nil, 0xB0},
+		{"other", RomOther, load, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOpts
+			o.RomVariant = tt.rom
+			d, r, _ := newReadyDev(t, o)
+			if err := d.WriteTemperature(2, 2, 21.5, 'C'); err != nil {
+				t.Fatal(err)
+			}
+			want := append(slices.Clone(tt.load), reading(tt.degree)...)
+			if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			// The glyph stays loaded for the next reading.
+			if err := d.WriteTemperature(2, 2, 21.5, 'C'); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := latched(t, d, r.take()), reading(tt.degree); !slices.Equal(got, want) {
+				t.Errorf("second reading sent %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteTemperatureLineEnd(t *testing.T) {
+	// Only the digits fit; the ring and unit are truncated with them.
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	if err := d.WriteTemperature(1, 12, 21.5, 'C'); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]op{cmd(CMD_DDRAM_Set | 0x0C)}, text("21.5")...)
+	if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,961 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/i2c/i2ctest"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi/spitest"
+)
+
+// recorder is a port that keeps every byte written to it.
+type recorder struct {
+	mu    sync.Mutex
+	bytes []byte
+}
+
+func (r *recorder) String() string { return "recorder" }
+
+func (r *recorder) writeByte(b byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bytes = append(r.bytes, b)
+	return nil
+}
+
+// take returns the bytes written so far and forgets them.
+func (r *recorder) take() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.bytes
+	r.bytes = nil
+	return b
+}
+
+// testClock is a Clock that only moves on when slept on, and keeps the
+// sleeps.
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+}
+
+// take returns the sleeps so far and forgets them.
+func (c *testClock) take() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.sleeps
+	c.sleeps = nil
+	return s
+}
+
+// newTestDev runs the full init sequence for o on a recorder, with a
+// testClock, and returns them without forgetting what init wrote.
+func newTestDev(t testing.TB, o Opts) (*Dev, *recorder, *testClock) {
+	t.Helper()
+	r := &recorder{}
+	d, c := newTestDevOn(t, r, o)
+	return d, r, c
+}
+
+// newTestDevOn is newTestDev on port p.
+func newTestDevOn(t testing.TB, p port, o Opts) (*Dev, *testClock) {
+	t.Helper()
+	c := &testClock{now: time.Unix(0, 0)}
+	o.Clock = c
+	d, err := makeDev(context.Background(), p, false, &o)
+	if err != nil {
+		t.Fatalf("makeDev: %v", err)
+	}
+	return d, c
+}
+
+// newReadyDev is newTestDev with the init sequence already taken.
+func newReadyDev(t testing.TB, o Opts) (*Dev, *recorder, *testClock) {
+	t.Helper()
+	d, r, c := newTestDev(t, o)
+	r.take()
+	c.take()
+	return d, r, c
+}
+
+// op is one byte the controller latched: an instruction, or data with RS
+// high.
+type op struct {
+	data bool
+	b    byte
+}
+
+func cmd(b byte) op { return op{b: b} }
+
+func text(s string) []op {
+	var ops []op
+	for i := 0; i < len(s); i++ {
+		ops = append(ops, op{data: true, b: s[i]})
+	}
+	return ops
+}
+
+// latched decodes raw port bytes into what the controller read: a nibble on
+// each falling edge of EN, paired high then low.
+func latched(t testing.TB, d *Dev, raw []byte) []op {
+	t.Helper()
+	en := byte(1) << d.pins.EN
+	bit := func(b, pin byte) byte { return b >> pin & 1 }
+	var ops []op
+	var high *op
+	for i := 1; i < len(raw); i++ {
+		b := raw[i-1]
+		if b&en == 0 || raw[i]&en != 0 {
+			continue
+		}
+		n := bit(b, d.pins.D4) | bit(b, d.pins.D5)<<1 | bit(b, d.pins.D6)<<2 | bit(b, d.pins.D7)<<3
+		rs := bit(b, d.pins.RS) == 1
+		if high == nil {
+			high = &op{data: rs, b: n << 4}
+			continue
+		}
+		if high.data != rs {
+			t.Fatalf("RS changed between nibbles at byte %d", i)
+		}
+		ops = append(ops, op{data: rs, b: high.b | n})
+		high = nil
+	}
+	if high != nil {
+		t.Fatalf("odd number of nibbles in %x", raw)
+	}
+	return ops
+}
+
+// ddram models the controller's display RAM and address counter in 2-line
+// mode, so tests can check where data really lands whatever the driver
+// believes.
+type ddram struct {
+	cells [0x68]byte
+	addr  byte
+	dec   bool // entry mode without OPT_Increment
+}
+
+// newDDRAM returns a ddram as init leaves it: blank, at 0x00, incrementing.
+func newDDRAM() *ddram {
+	m := &ddram{}
+	for i := range m.cells {
+		m.cells[i] = ' '
+	}
+	return m
+}
+
+// apply runs ops on m. Only the instructions that move the counter or set
+// the entry mode are modelled.
+func (m *ddram) apply(ops []op) {
+	for _, o := range ops {
+		switch {
+		case o.data:
+			m.cells[m.addr] = o.b
+			switch {
+			case !m.dec && m.addr == 0x27:
+				m.addr = 0x40
+			case !m.dec && m.addr == 0x67:
+				m.addr = 0x00
+			case m.dec && m.addr == 0x00:
+				m.addr = 0x67
+			case m.dec && m.addr == 0x40:
+				m.addr = 0x27
+			case m.dec:
+				m.addr--
+			default:
+				m.addr++
+			}
+		case o.b&CMD_DDRAM_Set != 0:
+			m.addr = o.b &^ CMD_DDRAM_Set
+		case o.b&^0x03 == CMD_Entry_Mode:
+			m.dec = o.b&OPT_Increment == 0
+		}
+	}
+}
+
+// row returns n cells of DDRAM from addr.
+func (m *ddram) row(addr, n byte) string {
+	return string(m.cells[addr : addr+n])
+}
+
+// checkPulses fails unless raw is made of EN pulses: each nibble latched
+// with the same byte written before, during and after EN high.
+func checkPulses(t testing.TB, d *Dev, raw []byte) {
+	t.Helper()
+	en := byte(1) << d.pins.EN
+	if len(raw)%3 != 0 {
+		t.Fatalf("%d bytes is not a whole number of pulses: %x", len(raw), raw)
+	}
+	for i := 0; i < len(raw); i += 3 {
+		base := raw[i]
+		if base&en != 0 || raw[i+1] != base|en || raw[i+2] != base {
+			t.Fatalf("pulse at byte %d is %x, want %02x %02x %02x", i, raw[i:i+3], base, base|en, base)
+		}
+	}
+}
+
+func TestInitPulses(t *testing.T) {
+	d, r, _ := newTestDev(t, DefaultOpts)
+	raw := r.take()
+	checkPulses(t, d, raw)
+	// The four single nibbles that put the controller in 4-bit mode come
+	// first, with the backlight off, RS and RW low.
+	want := []byte{
+		0x30, 0x34, 0x30,
+		0x30, 0x34, 0x30,
+		0x30, 0x34, 0x30,
+		0x20, 0x24, 0x20,
+	}
+	if len(raw) < len(want) || !slices.Equal(raw[:len(want)], want) {
+		t.Fatalf("init nibbles = %x, want %x", raw[:min(len(raw), len(want))], want)
+	}
+	got := latched(t, d, raw[len(want):])
+	wantOps := []op{
+		cmd(CMD_Function_Set | OPT_2_Lines),
+		cmd(CMD_Display_Control | OPT_Enable_Display),
+		cmd(CMD_Entry_Mode | OPT_Increment),
+		cmd(CMD_Clear_Display),
+		cmd(CMD_Entry_Mode | OPT_Increment),
+	}
+	if !slices.Equal(got, wantOps) {
+		t.Errorf("init instructions = %v, want %v", got, wantOps)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Opts
+		do   func(d *Dev) error
+		want []op
+	}{
+		{"Clear", DefaultOpts, (*Dev).Clear, []op{cmd(CMD_Clear_Display), cmd(CMD_Entry_Mode | OPT_Increment)}},
+		{"Home", DefaultOpts, (*Dev).Home, []op{cmd(CMD_Return_Home)}},
+		{"SetPosition 1", Opts20x4, func(d *Dev) error { return d.SetPosition(1, 0) }, []op{cmd(CMD_DDRAM_Set | 0x00)}},
+		{"SetPosition 2", Opts20x4, func(d *Dev) error { return d.SetPosition(2, 0) }, []op{cmd(CMD_DDRAM_Set | 0x40)}},
+		{"SetPosition 3", Opts20x4, func(d *Dev) error { return d.SetPosition(3, 0) }, []op{cmd(CMD_DDRAM_Set | 0x14)}},
+		{"SetPosition 4", Opts20x4, func(d *Dev) error { return d.SetPosition(4, 0) }, []op{cmd(CMD_DDRAM_Set | 0x54)}},
+		{"SetPosition 4 col 19", Opts20x4, func(d *Dev) error { return d.SetPosition(4, 19) }, []op{cmd(CMD_DDRAM_Set | 0x67)}},
+		{"SetPosition 16x2 line 2 col 5", DefaultOpts, func(d *Dev) error { return d.SetPosition(2, 5) }, []op{cmd(CMD_DDRAM_Set | 0x45)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, tt.opts)
+			if err := tt.do(d); err != nil {
+				t.Fatal(err)
+			}
+			raw := r.take()
+			checkPulses(t, d, raw)
+			if got := latched(t, d, raw); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPositionBounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Opts
+		line, col byte
+		want      error
+	}{
+		{"16x2 last cell", DefaultOpts, 2, 15, nil},
+		{"16x2 line 0", DefaultOpts, 0, 0, ErrLineOutOfRange},
+		{"16x2 line 3", DefaultOpts, 3, 0, ErrLineOutOfRange},
+		{"16x2 col 16", DefaultOpts, 1, 16, ErrColOutOfRange},
+		{"20x4 last cell", Opts20x4, 4, 19, nil},
+		{"20x4 line 5", Opts20x4, 5, 0, ErrLineOutOfRange},
+		{"20x4 col 20", Opts20x4, 3, 20, ErrColOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, tt.opts)
+			err := d.SetPosition(tt.line, tt.col)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("SetPosition(%d, %d) = %v", tt.line, tt.col, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("SetPosition(%d, %d) = %v, want %v", tt.line, tt.col, err, tt.want)
+			}
+			if raw := r.take(); len(raw) != 0 {
+				t.Errorf("rejected SetPosition wrote %x", raw)
+			}
+		})
+	}
+}
+
+func TestLineOffsetPresets(t *testing.T) {
+	o16x4 := DefaultOpts
+	o16x4.Lines, o16x4.Cols = 4, 16
+	o16x4.LineOffsets = LineOffsets16x4Alt
+	tests := []struct {
+		name string
+		opts Opts
+		want []byte // DDRAM address of the last cell of each line
+	}{
+		{"LineOffsets16x4Alt", o16x4, []byte{0x0F, 0x4F, 0x23, 0x63}},
+		{"Opts16x1Split", Opts16x1Split, []byte{0x07, 0x47}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, tt.opts)
+			for i, addr := range tt.want {
+				line := byte(i + 1)
+				if err := d.SetPosition(line, tt.opts.Cols-1); err != nil {
+					t.Fatalf("SetPosition(%d, %d): %v", line, tt.opts.Cols-1, err)
+				}
+				if got, want := latched(t, d, r.take()), []op{cmd(CMD_DDRAM_Set | addr)}; !slices.Equal(got, want) {
+					t.Errorf("line %d: got %v, want %v", line, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDisplayControl(t *testing.T) {
+	const ctl = CMD_Display_Control
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	steps := []struct {
+		name string
+		do   func() error
+		want byte
+	}{
+		{"cursor on", func() error { return d.SetCursor(true) }, ctl | OPT_Enable_Display | OPT_Enable_Cursor},
+		{"blink on", func() error { return d.SetBlink(true) }, ctl | OPT_Enable_Display | OPT_Enable_Cursor | OPT_Enable_Blink},
+		{"display off", func() error { return d.SetDisplay(false) }, ctl | OPT_Enable_Cursor | OPT_Enable_Blink},
+		{"cursor off", func() error { return d.SetCursor(false) }, ctl | OPT_Enable_Blink},
+		{"display on", func() error { return d.SetDisplay(true) }, ctl | OPT_Enable_Display | OPT_Enable_Blink},
+		{"blink off", func() error { return d.SetBlink(false) }, ctl | OPT_Enable_Display},
+	}
+	for _, s := range steps {
+		if err := s.do(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got, want := latched(t, d, r.take()), []op{cmd(s.want)}; !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, want %v", s.name, got, want)
+		}
+	}
+}
+
+func TestSlowCommandDelays(t *testing.T) {
+	// Each instruction is two EN pulses, each with a settle and a pulse
+	// time, and then its execution time.
+	instr := func(exec time.Duration) []time.Duration {
+		const e = defaultEnableTiming
+		return []time.Duration{e, e, e, e, exec}
+	}
+	tests := []struct {
+		name  string
+		clear time.Duration
+		home  time.Duration
+		do    func(d *Dev) error
+		want  []time.Duration
+	}{
+		{"Clear default", 0, 0, (*Dev).Clear, append(instr(defaultSlowCommandDelay), instr(execTime)...)},
+		{"Clear set", 3 * time.Millisecond, 0, (*Dev).Clear, append(instr(3*time.Millisecond), instr(execTime)...)},
+		{"Home default", 0, 0, (*Dev).Home, instr(defaultSlowCommandDelay)},
+		{"Home set", 0, 1700 * time.Microsecond, (*Dev).Home, instr(1700 * time.Microsecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOpts
+			o.ClearDelay, o.HomeDelay = tt.clear, tt.home
+			d, _, c := newReadyDev(t, o)
+			if err := tt.do(d); err != nil {
+				t.Fatal(err)
+			}
+			if got := c.take(); !slices.Equal(got, tt.want) {
+				t.Errorf("sleeps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryModeDirection(t *testing.T) {
+	tests := []struct {
+		name      string
+		increment bool
+		want      string
+		col       byte // cursor after "AB"
+	}{
+		{"increment", true, "     AB         ", 7},
+		{"decrement", false, "    BA          ", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, DefaultOpts)
+			if err := d.SetIncrement(tt.increment); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.SetPosition(1, 5); err != nil {
+				t.Fatal(err)
+			}
+			r.take()
+			if _, err := d.Write([]byte("AB")); err != nil {
+				t.Fatal(err)
+			}
+			// The controller moves the address counter itself, so no
+			// positioning is sent between the characters.
+			if got, want := latched(t, d, r.take()), text("AB"); !slices.Equal(got, want) {
+				t.Errorf("sent %v, want %v", got, want)
+			}
+			if got := d.Snapshot()[:16]; got != tt.want {
+				t.Errorf("line 1 = %q, want %q", got, tt.want)
+			}
+			if line, col := d.Position(); line != 1 || col != tt.col {
+				t.Errorf("cursor at line %d col %d, want line 1 col %d", line, col, tt.col)
+			}
+		})
+	}
+}
+
+func TestTinyEnableTiming(t *testing.T) {
+	o := DefaultOpts
+	o.EnableSettleTime, o.EnablePulseWidth = time.Nanosecond, time.Nanosecond
+	d, r, c := newReadyDev(t, o)
+	if err := d.WriteChar('A'); err != nil {
+		t.Fatal(err)
+	}
+	raw := r.take()
+	// Low, high, low for each nibble however short the waits between.
+	want := []byte{0x41, 0x45, 0x41, 0x11, 0x15, 0x11}
+	if !slices.Equal(raw, want) {
+		t.Fatalf("wrote %x, want %x", raw, want)
+	}
+	checkPulses(t, d, raw)
+	sleeps := c.take()
+	if len(sleeps) < 4 || !slices.Equal(sleeps[:4], []time.Duration{1, 1, 1, 1}) {
+		t.Errorf("sleeps = %v, want four of 1ns first", sleeps)
+	}
+}
+
+func TestPinMap(t *testing.T) {
+	o := DefaultOpts
+	// Data on the low nibble, control lines and backlight above it.
+	o.PinMap = PinMap{D4: 0, D5: 1, D6: 2, D7: 3, RS: 4, RW: 5, EN: 6, Backlight: 7}
+	d, r, _ := newReadyDev(t, o)
+	if err := d.SetBacklight(true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.take(), []byte{0x80}; !slices.Equal(got, want) {
+		t.Errorf("SetBacklight(true) wrote %x, want %x", got, want)
+	}
+	if err := d.WriteChar('A'); err != nil {
+		t.Fatal(err)
+	}
+	// 'A' is 0x41: nibble 4 then 1, with RS and the backlight set.
+	want := []byte{0x94, 0xD4, 0x94, 0x91, 0xD1, 0x91}
+	if got := r.take(); !slices.Equal(got, want) {
+		t.Errorf("WriteChar('A') wrote %x, want %x", got, want)
+	}
+}
+
+// flaky is a recorder whose next fail writes are refused.
+type flaky struct {
+	recorder
+	fail int
+}
+
+func (f *flaky) writeByte(b byte) error {
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("nack")
+	}
+	return f.recorder.writeByte(b)
+}
+
+func TestWriteRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    int
+		retries int
+		wantErr bool
+	}{
+		{"no failures", 0, 0, false},
+		{"retried", 2, 3, false},
+		{"all retries used", 3, 3, false},
+		{"too many failures", 4, 3, true},
+		{"retries off", 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOpts
+			o.WriteRetries, o.RetryBackoff = tt.retries, 100*time.Millisecond
+			f := &flaky{}
+			d, c := newTestDevOn(t, f, o)
+			f.take()
+			c.take()
+			f.fail = tt.fail
+			err := d.WriteChar('A')
+			if tt.wantErr {
+				if !errors.Is(err, ErrBusWrite) {
+					t.Fatalf("WriteChar = %v, want ErrBusWrite", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The failed first byte is sent again, then the rest as usual.
+			if got, want := f.take(), []byte{0x41, 0x45, 0x41, 0x11, 0x15, 0x11}; !slices.Equal(got, want) {
+				t.Errorf("landed %x, want %x", got, want)
+			}
+			var backoff []time.Duration
+			for _, s := range c.take() {
+				// Far longer than any of the command waits.
+				if s >= 100*time.Millisecond {
+					backoff = append(backoff, s)
+				}
+			}
+			want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}[:tt.fail]
+			if !slices.Equal(backoff, want) {
+				t.Errorf("backoff = %v, want %v", backoff, want)
+			}
+		})
+	}
+}
+
+// burster is a recorder that also takes six byte bursts, counting each
+// write or burst as one bus transaction. Its next fail bursts are refused.
+type burster struct {
+	recorder
+	txns int
+	fail int
+}
+
+func (b *burster) writeByte(c byte) error {
+	b.txns++
+	return b.recorder.writeByte(c)
+}
+
+func (b *burster) writeBytes(buf []byte) error {
+	b.txns++
+	if b.fail > 0 {
+		b.fail--
+		return errors.New("nack")
+	}
+	for _, c := range buf {
+		b.recorder.writeByte(c)
+	}
+	return nil
+}
+
+func TestFastWrite(t *testing.T) {
+	o := DefaultOpts
+	o.FastWrite = true
+	b := &burster{}
+	d, _ := newTestDevOn(t, b, o)
+	b.take()
+	b.txns = 0
+	if _, err := d.WriteString("Hi"); err != nil {
+		t.Fatal(err)
+	}
+	// The same pulses as the nibble by nibble path, one burst a byte.
+	raw := b.take()
+	checkPulses(t, d, raw)
+	if got, want := latched(t, d, raw), text("Hi"); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if b.txns != 2 {
+		t.Errorf("%d transactions, want 2", b.txns)
+	}
+}
+
+func TestFastWriteRetries(t *testing.T) {
+	o := DefaultOpts
+	o.FastWrite, o.WriteRetries = true, 2
+	b := &burster{}
+	d, _ := newTestDevOn(t, b, o)
+	b.take()
+	b.fail = 2
+	if err := d.WriteChar('A'); err != nil {
+		t.Fatalf("WriteChar with 2 refused bursts and 2 retries: %v", err)
+	}
+	if got, want := latched(t, d, b.take()), text("A"); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	b.fail = 3
+	if err := d.WriteChar('B'); !errors.Is(err, ErrBusWrite) {
+		t.Errorf("WriteChar with 3 refused bursts = %v, want ErrBusWrite", err)
+	}
+}
+
+// BenchmarkFastWrite compares the bus transactions of a full line written
+// with FastWrite bursts and nibble by nibble.
+func BenchmarkFastWrite(b *testing.B) {
+	for _, fast := range []bool{true, false} {
+		name := "Nibbles"
+		if fast {
+			name = "Burst"
+		}
+		b.Run(name, func(b *testing.B) {
+			o := DefaultOpts
+			o.FastWrite = fast
+			p := &burster{}
+			d, _ := newTestDevOn(b, p, o)
+			p.txns = 0
+			for i := 0; i < b.N; i++ {
+				if err := d.WriteLine(1, "0123456789abcdef"); err != nil {
+					b.Fatal(err)
+				}
+				p.take()
+			}
+			b.ReportMetric(float64(p.txns)/float64(b.N), "txns/op")
+		})
+	}
+}
+
+func TestBacklightActiveLow(t *testing.T) {
+	for _, activeLow := range []bool{false, true} {
+		o := DefaultOpts
+		o.BacklightActiveLow = activeLow
+		d, r, _ := newReadyDev(t, o)
+		on, off := byte(1<<BACKLIGHT), byte(0)
+		if activeLow {
+			on, off = off, on
+		}
+		if err := d.SetBacklight(true); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.take(); !slices.Equal(got, []byte{on}) {
+			t.Errorf("active low %v: SetBacklight(true) wrote %x, want %02x", activeLow, got, on)
+		}
+		// Every later write keeps the backlight as it is.
+		if err := d.WriteChar('A'); err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range r.take() {
+			if b&(1<<BACKLIGHT) != on {
+				t.Errorf("active low %v: WriteChar wrote %02x, backlight bit should be %02x", activeLow, b, on)
+			}
+		}
+		if err := d.SetBacklight(false); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.take(); !slices.Equal(got, []byte{off}) {
+			t.Errorf("active low %v: SetBacklight(false) wrote %x, want %02x", activeLow, got, off)
+		}
+	}
+}
+
+func TestInitSequence(t *testing.T) {
+	d, r, c := newTestDev(t, DefaultOpts)
+	const e = defaultEnableTiming
+	// Power-on wait, then each nibble's pulse and the wait after it.
+	wantSleeps := []time.Duration{
+		50 * time.Millisecond,
+		e, e, 5 * time.Millisecond,
+		e, e, 200 * time.Microsecond,
+		e, e, 200 * time.Microsecond,
+		e, e, 200 * time.Microsecond,
+	}
+	if got := c.take(); len(got) < len(wantSleeps) || !slices.Equal(got[:len(wantSleeps)], wantSleeps) {
+		t.Errorf("init sleeps start %v, want %v", got[:min(len(got), len(wantSleeps))], wantSleeps)
+	}
+	r.take()
+	// Reset sends the same nibbles, keeping the backlight as it is.
+	if err := d.SetBacklight(true); err != nil {
+		t.Fatal(err)
+	}
+	r.take()
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	raw := r.take()
+	var nibbles []byte
+	for i := 0; i < 12 && i < len(raw); i += 3 {
+		if raw[i]&(1<<BACKLIGHT) == 0 {
+			t.Errorf("reset byte %d is %02x, backlight should stay on", i, raw[i])
+		}
+		nibbles = append(nibbles, raw[i]>>4)
+	}
+	if want := []byte{0x03, 0x03, 0x03, 0x02}; !slices.Equal(nibbles, want) {
+		t.Errorf("reset nibbles = %x, want %x", nibbles, want)
+	}
+}
+
+func TestClearKeepsEntryMode(t *testing.T) {
+	tests := []struct {
+		name                    string
+		increment, shiftDisplay bool
+		want                    byte
+	}{
+		{"default", true, false, CMD_Entry_Mode | OPT_Increment},
+		{"decrement", false, false, CMD_Entry_Mode},
+		{"decrement with shift", false, true, CMD_Entry_Mode | OPT_Cursor_Shift},
+		{"increment with shift", true, true, CMD_Entry_Mode | OPT_Increment | OPT_Cursor_Shift},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, DefaultOpts)
+			if err := d.SetEntryMode(tt.increment, tt.shiftDisplay); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.SetCursorMode(CursorBlink); err != nil {
+				t.Fatal(err)
+			}
+			r.take()
+			if err := d.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			// Only the entry mode needs restoring; display control survives.
+			want := []op{cmd(CMD_Clear_Display), cmd(tt.want)}
+			if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if inc, shift := d.EntryMode(); inc != tt.increment || shift != tt.shiftDisplay {
+				t.Errorf("EntryMode = %v, %v after Clear, want %v, %v", inc, shift, tt.increment, tt.shiftDisplay)
+			}
+		})
+	}
+}
+
+func TestWriteCharDirection(t *testing.T) {
+	for _, increment := range []bool{true, false} {
+		d, r, _ := newReadyDev(t, DefaultOpts)
+		if err := d.SetIncrement(increment); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetPosition(2, 8); err != nil {
+			t.Fatal(err)
+		}
+		step, col := 1, 8
+		if !increment {
+			step = -1
+		}
+		r.take()
+		for _, c := range []byte("xyz") {
+			if err := d.WriteChar(c); err != nil {
+				t.Fatal(err)
+			}
+			col += step
+			if line, got := d.Position(); line != 2 || int(got) != col {
+				t.Errorf("increment %v: after %q cursor at line %d col %d, want line 2 col %d", increment, c, line, got, col)
+			}
+		}
+		if got, want := latched(t, d, r.take()), text("xyz"); !slices.Equal(got, want) {
+			t.Errorf("increment %v: sent %v, want %v", increment, got, want)
+		}
+		want := "        xyz     "
+		if !increment {
+			want = "      zyx       "
+		}
+		if got := d.Snapshot()[17:]; got != want {
+			t.Errorf("increment %v: line 2 = %q, want %q", increment, got, want)
+		}
+	}
+}
+
+func TestWriteCharAdjacent(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	for _, c := range []byte("XY") {
+		if err := d.WriteChar(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing but the two characters: no cursor shift between them.
+	if got, want := latched(t, d, r.take()), text("XY"); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	if got, want := d.Snapshot()[:3], "XY "; got != want {
+		t.Errorf("line 1 starts %q, want %q", got, want)
+	}
+}
+
+func TestWriteCharError(t *testing.T) {
+	f := &flaky{}
+	d, _ := newTestDevOn(t, f, DefaultOpts)
+	f.fail = 1
+	if err := d.WriteChar('X'); !errors.Is(err, ErrBusWrite) {
+		t.Errorf("WriteChar on a failing bus = %v, want ErrBusWrite", err)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		fast bool
+		do   func(d *Dev) error
+	}{
+		{"SendCommand", false, func(d *Dev) error { return d.SendCommand(CMD_Return_Home) }},
+		{"FastWrite SendCommand", true, func(d *Dev) error { return d.SendCommand(CMD_Return_Home) }},
+		{"WriteString", true, func(d *Dev) error { _, err := d.WriteString("x"); return err }},
+		{"batched WriteString", false, func(d *Dev) error {
+			d.BeginBatch()
+			_, err := d.WriteString("x")
+			return err
+		}},
+		{"batched Clear", false, func(d *Dev) error {
+			d.BeginBatch()
+			return d.Clear()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOpts
+			o.FastWrite = tt.fast
+			b := &burster{}
+			d, _ := newTestDevOn(t, b, o)
+			if err := d.Close(); err != nil {
+				t.Fatal(err)
+			}
+			b.take()
+			if err := tt.do(d); !errors.Is(err, ErrClosed) {
+				t.Errorf("after Close: %v, want ErrClosed", err)
+			}
+			if err := d.Flush(); err != nil && !errors.Is(err, ErrClosed) {
+				t.Errorf("Flush after Close: %v, want nil or ErrClosed", err)
+			}
+			if raw := b.take(); len(raw) != 0 {
+				t.Errorf("wrote %x after Close", raw)
+			}
+		})
+	}
+}
+
+func TestHaltAgain(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	for i := 0; i < 2; i++ {
+		if err := d.SetBacklight(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetDisplay(true); err != nil {
+			t.Fatal(err)
+		}
+		r.take()
+		if err := d.Halt(); err != nil {
+			t.Fatalf("Halt %d: %v", i+1, err)
+		}
+		raw := r.take()
+		if len(raw) == 0 || raw[len(raw)-1] != 0x00 {
+			t.Fatalf("Halt %d wrote %x, want it to end with the backlight off", i+1, raw)
+		}
+		if got, want := latched(t, d, raw[:len(raw)-1]), []op{
+			cmd(CMD_Clear_Display),
+			cmd(CMD_Entry_Mode | OPT_Increment),
+			cmd(CMD_Display_Control),
+		}; !slices.Equal(got, want) {
+			t.Errorf("Halt %d sent %v, want %v", i+1, got, want)
+		}
+		if d.BacklightOn() || d.DisplayOn() {
+			t.Errorf("Halt %d left backlight %v, display %v", i+1, d.BacklightOn(), d.DisplayOn())
+		}
+	}
+}
+
+// ackBus is an I2C bus where every address acknowledges and reads as 0.
+type ackBus struct{}
+
+func (ackBus) String() string                    { return "ackBus" }
+func (ackBus) Tx(addr uint16, w, r []byte) error { clear(r); return nil }
+func (ackBus) SetSpeed(f physic.Frequency) error { return nil }
+
+func TestStatusAddress(t *testing.T) {
+	tests := []struct {
+		addr uint16
+		want string
+	}{
+		{0, " at 0x27"},
+		{0x3f, " at 0x3f"},
+	}
+	for _, tt := range tests {
+		bus := &i2ctest.Record{Bus: ackBus{}}
+		o := DefaultOpts
+		o.I2CAddr, o.Clock = tt.addr, &testClock{}
+		d, err := NewI2C(bus, &o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Status(); !strings.Contains(got, tt.want) {
+			t.Errorf("I2CAddr %#x: Status = %q, want it to contain %q", tt.addr, got, tt.want)
+		}
+		if a := bus.Ops[0].Addr; fmt.Sprintf(" at %#x", a) != tt.want {
+			t.Errorf("I2CAddr %#x: wrote to %#x", tt.addr, a)
+		}
+	}
+}
+
+func TestSPIStream(t *testing.T) {
+	port := &spitest.Record{}
+	o := DefaultOpts
+	o.Clock = &testClock{}
+	d, err := NewSPI(port, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port.Lock()
+	port.Ops = nil
+	port.Unlock()
+	if _, err := d.WriteString("H"); err != nil {
+		t.Fatal(err)
+	}
+	port.Lock()
+	defer port.Unlock()
+	var raw []byte
+	for i, io := range port.Ops {
+		if len(io.W) != 1 || len(io.R) != 0 {
+			t.Fatalf("transfer %d is %x read %x, want one byte shifted out", i, io.W, io.R)
+		}
+		raw = append(raw, io.W[0])
+	}
+	// 'H' with RS high and the backlight still off: nibble 0x4 then 0x8,
+	// each latched by one EN pulse.
+	want := []byte{0x41, 0x45, 0x41, 0x81, 0x85, 0x81}
+	if !slices.Equal(raw, want) {
+		t.Errorf("shifted out %x, want %x", raw, want)
+	}
+}
+
+func TestNilPort(t *testing.T) {
+	if _, err := NewI2C(nil, nil); err == nil || err.Error() != "lcd1602: nil I²C bus" {
+		t.Errorf("NewI2C(nil) = %v", err)
+	}
+	if _, err := NewSPI(nil, nil); err == nil || err.Error() != "lcd1602: nil SPI port" {
+		t.Errorf("NewSPI(nil) = %v", err)
+	}
+}
+
+func TestDetectI2CReadsOnly(t *testing.T) {
+	bus := &i2ctest.Record{Bus: ackBus{}}
+	found, err := DetectI2C(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 16 || found[0] != 0x20 || found[15] != 0x3F {
+		t.Errorf("found %#x, want 0x20-0x27 and 0x38-0x3f", found)
+	}
+	for _, io := range bus.Ops {
+		if len(io.W) != 0 {
+			t.Errorf("probe of %#x wrote %x", io.Addr, io.W)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+/*
+Copyright 2024 Tim St. Pierre
+Text helpers for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tabWidth is the tab stop spacing used by WriteString.
+const tabWidth = 4
+
+// WriteString writes s starting at the current cursor. Text that runs past
+// the end of a line is dropped, or wrapped onto the next line, as
+// Opts.Overflow says.
+//
+// Each rune is translated to the controller's character ROM, see
+// Opts.CharMap. Like a terminal, '\n' moves to the start of the next line,
+// '\r' to the start of the current line and '\t' to the next multiple of
+// four columns. Dropped runes count as consumed. With OverflowWrap writing
+// stops at the end of the last line; the number of bytes consumed is
+// returned along with io.ErrShortWrite if s did not fit. Use Write to stream
+// raw bytes without translation.
+func (d *Dev) WriteString(s string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeString(s)
+}
+
+// WriteRunes is like WriteString for text that is already a rune slice. It
+// returns the number of runes consumed.
+func (d *Dev) WriteRunes(rs []rune) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeRunes(rs)
+}
+
+func (d *Dev) writeString(s string) (int, error) {
+	n, err := d.writeRunes([]rune(s))
+	// Convert runes consumed back to bytes; invalid UTF-8 decodes to one
+	// rune per byte both here and in the conversion above.
+	consumed := 0
+	for ; n > 0; n-- {
+		_, size := utf8.DecodeRuneInString(s[consumed:])
+		consumed += size
+	}
+	return consumed, err
+}
+
+func (d *Dev) writeRunes(rs []rune) (int, error) {
+	return d.typeRunes(rs, nil)
+}
+
+// typeRunes is writeRunes calling pace, if not nil, before every character
+// after the first that is sent. pace may let go of d.mu, so the cursor is
+// positioned again after it.
+func (d *Dev) typeRunes(rs []rune, pace func() error) (int, error) {
+	return d.typeCodes(rs, d.mapRune, pace)
+}
+
+// typeCodes is typeRunes sending code(r) for each rune r rather than its
+// mapRune translation.
+func (d *Dev) typeCodes(rs []rune, code func(rune) byte, pace func() error) (int, error) {
+	policy := d.overflow()
+	line, col, ok := d.writePosition()
+	if !ok {
+		if policy == OverflowTruncate {
+			return len(rs), nil
+		}
+		return 0, io.ErrShortWrite
+	}
+	// Cursor moves are applied lazily so that a trailing newline on the last
+	// line, or a tab running off the end, is not an error by itself.
+	seek, sent := false, false
+	for i, r := range rs {
+		switch r {
+		case '\n':
+			line, col, seek = line+1, 0, true
+			continue
+		case '\r':
+			col, seek = 0, true
+			continue
+		case '\t':
+			col, seek = (col/tabWidth+1)*tabWidth, true
+			continue
+		}
+		if col >= d.opts.Cols {
+			switch policy {
+			case OverflowTruncate:
+				continue
+			case OverflowWrap:
+				line, col, seek = line+1, 0, true
+			}
+		}
+		if line > d.opts.Lines {
+			if policy == OverflowTruncate {
+				continue
+			}
+			return i, io.ErrShortWrite
+		}
+		if pace != nil && sent {
+			if err := pace(); err != nil {
+				return i, err
+			}
+			seek = true
+		}
+		if seek {
+			if err := d.setPosition(line, col); err != nil {
+				return i, err
+			}
+			seek = false
+		}
+		if _, err := d.writeData([]byte{code(r)}); err != nil {
+			return i, err
+		}
+		col++
+		sent = true
+	}
+	if seek && line <= d.opts.Lines && col < d.opts.Cols {
+		if err := d.setPosition(line, col); err != nil {
+			return len(rs), err
+		}
+	}
+	return len(rs), nil
+}
+
+// WriteAt writes s at col on line, translating it and handling overflow like
+// WriteString, and leaves the cursor after the text. An off-screen position
+// is reported before anything is written.
+func (d *Dev) WriteAt(line, col byte, s string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	_, err := d.writeString(s)
+	return err
+}
+
+// TypeText writes s at col on line one rune every perChar, with the blinking
+// cursor shown, like a teletype. It translates s and handles overflow like
+// WriteString. The cursor mode is put back afterwards, also when ctx is done
+// part way, in which case ctx.Err() is returned.
+//
+// TypeText blocks until the text is out; other calls may run between the
+// characters.
+func (d *Dev) TypeText(line, col byte, s string, perChar time.Duration, ctx context.Context) (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cursor, blink := d.cursor, d.blink
+	defer func() {
+		d.cursor, d.blink = cursor, blink
+		if restoreErr := d.writeDisplaySwitch(); err == nil {
+			err = restoreErr
+		}
+	}()
+	d.cursor, d.blink = true, true
+	if err := d.writeDisplaySwitch(); err != nil {
+		return err
+	}
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	_, err = d.typeRunes([]rune(s), func() error {
+		d.mu.Unlock()
+		defer d.mu.Lock()
+		return d.hold(ctx, perChar)
+	})
+	return err
+}
+
+// Printf formats according to format and writes the result at col on line.
+//
+// Output that would run past the end of the line is truncated rather than
+// wrapped, so a status field never spills into the next line.
+func (d *Dev) Printf(line, col byte, format string, args ...interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	text := []rune(fmt.Sprintf(format, args...))
+	if room := int(d.opts.Cols - col); len(text) > room {
+		text = text[:room]
+	}
+	_, err := d.writeString(string(text))
+	return err
+}
+
+// WriteLine replaces the whole of line with s: s is translated, truncated
+// to the width and padded with spaces, so nothing of a longer earlier text
+// is left behind. The cursor ends at the first column of line.
+func (d *Dev) WriteLine(line byte, s string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.writeAligned(line, s, AlignLeft); err != nil {
+		return err
+	}
+	return d.setPosition(line, 0)
+}
+
+// WriteNumber writes value with decimals digits after the point,
+// right-aligned in a field of width cells at col on line, so the digits stay
+// put as the magnitude changes. A value too wide for the field fills it
+// with '#' rather than show misleading digits. The cursor ends after the
+// field.
+func (d *Dev) WriteNumber(line, col, width byte, value float64, decimals int) error {
+	return d.WriteNumberPadded(line, col, width, value, decimals, false)
+}
+
+// WriteNumberPadded is WriteNumber with leading zeros instead of spaces when
+// zeroPad is set, after any minus sign.
+func (d *Dev) WriteNumberPadded(line, col, width byte, value float64, decimals int, zeroPad bool) error {
+	if decimals < 0 {
+		decimals = 0
+	}
+	format := "%*.*f"
+	if zeroPad {
+		format = "%0*.*f"
+	}
+	text := fmt.Sprintf(format, int(width), decimals, value)
+	if len(text) > int(width) {
+		text = strings.Repeat("#", int(width))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	_, err := d.writeString(text)
+	return err
+}
+
+// Backspace deletes the character before the cursor, as a text field does:
+// the cell to the left is blanked and the cursor left on it. At the start
+// of a line it deletes the last character of the line above. At the top
+// left it does nothing.
+func (d *Dev) Backspace() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	line, col, ok := d.writePosition()
+	switch {
+	case !ok:
+		// Just past the end of a line: step back onto its last cell.
+		addr := d.addr
+		d.step(!d.forward())
+		line, col, ok = d.position()
+		d.addr = addr
+		if !ok {
+			return nil
+		}
+	case col > 0:
+		col--
+	case line > 1:
+		line, col = line-1, d.opts.Cols-1
+	default:
+		return nil
+	}
+	if err := d.setPosition(line, col); err != nil {
+		return err
+	}
+	if _, err := d.writeData([]byte{' '}); err != nil {
+		return err
+	}
+	return d.setPosition(line, col)
+}
+
+// ClearLine blanks a single line and leaves the cursor at its first column.
+//
+// It is much quicker than Clear and leaves the other lines untouched.
+func (d *Dev) ClearLine(line byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clearLine(line)
+}
+
+func (d *Dev) clearLine(line byte) error {
+	if err := d.setPosition(line, 0); err != nil {
+		return err
+	}
+	if _, err := d.writeData(bytes.Repeat([]byte{' '}, int(d.opts.Cols))); err != nil {
+		return err
+	}
+	return d.setPosition(line, 0)
+}
+
+// writeDiff writes next at col on line, skipping cells that already hold
+// the same byte in prev. Each run of changed cells costs one positioning
+// command, so small edits stay cheap on the bus. The runs go out left to
+// right whatever the entry mode, see inTextOrder.
+func (d *Dev) writeDiff(line, col byte, prev, next []byte) error {
+	return d.inTextOrder(func() error {
+		for i := 0; i < len(next); {
+			if i < len(prev) && prev[i] == next[i] {
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(next) && (j >= len(prev) || prev[j] != next[j]) {
+				j++
+			}
+			if err := d.setPosition(line, col+byte(i)); err != nil {
+				return err
+			}
+			if _, err := d.writeData(next[i:j]); err != nil {
+				return err
+			}
+			i = j
+		}
+		return nil
+	})
+}
+
+// Alignment positions text within a line for WriteAligned.
+type Alignment uint8
+
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+)
+
+// WriteAligned writes text on line padded with spaces to the full width, so
+// the text sits at the left, centre or right. With AlignCenter an odd amount
+// of padding puts the extra space on the right. Text longer than the line is
+// truncated.
+func (d *Dev) WriteAligned(line byte, text string, align Alignment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeAligned(line, text, align)
+}
+
+func (d *Dev) writeAligned(line byte, text string, align Alignment) error {
+	if err := d.setPosition(line, 0); err != nil {
+		return err
+	}
+	return d.inTextOrder(func() error {
+		_, err := d.writeString(alignText(text, int(d.opts.Cols), align))
+		return err
+	})
+}
+
+// WriteTitle clears the screen and centres title on line 1 and subtitle on
+// line 2, truncating each to the width, as for a splash screen. Any further
+// lines are left blank. It needs a display with at least two lines.
+func (d *Dev) WriteTitle(title, subtitle string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.opts.Lines < 2 {
+		return fmt.Errorf("lcd1602: WriteTitle needs 2 lines, display has %d", d.opts.Lines)
+	}
+	if err := d.clear(); err != nil {
+		return err
+	}
+	if err := d.writeAligned(1, title, AlignCenter); err != nil {
+		return err
+	}
+	return d.writeAligned(2, subtitle, AlignCenter)
+}
+
+// WriteWrapped fills the screen from the top left with s, word wrapped:
+// lines break at spaces, words that do not fit move whole to the next line,
+// and only words longer than a line are split. Runs of spaces collapse to
+// one, space at the start of a line is dropped and '\n' starts a new line.
+// Every line is rewritten, padded with spaces, and the cursor ends after the
+// last.
+//
+// It returns the number of bytes of s consumed, which is len(s) once all of
+// it is on screen, so the rest can be shown with WriteWrapped(s[n:]).
+func (d *Dev) WriteWrapped(s string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for line := byte(1); line <= d.opts.Lines; line++ {
+		row, used := wrapRow(s[n:], int(d.opts.Cols))
+		if err := d.writeAligned(line, row, AlignLeft); err != nil {
+			return n, err
+		}
+		n += used
+	}
+	if strings.TrimSpace(s[n:]) == "" {
+		n = len(s)
+	}
+	return n, nil
+}
+
+// wrapLines word wraps all of s into rows of at most width runes, as
+// WriteWrapped would show them a screenful at a time.
+func wrapLines(s string, width int) []string {
+	var rows []string
+	for strings.TrimSpace(s) != "" {
+		row, used := wrapRow(s, width)
+		if used == 0 {
+			break
+		}
+		rows = append(rows, row)
+		s = s[used:]
+	}
+	return rows
+}
+
+// wrapRow takes the first row of at most width runes off s for
+// WriteWrapped and Pager and returns it with the number of bytes it used up,
+// including the spaces and '\n' that end it.
+func wrapRow(s string, width int) (string, int) {
+	var row []rune
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i == len(s) {
+			return string(row), i
+		}
+		if s[i] == '\n' || s[i] == '\r' {
+			if strings.HasPrefix(s[i:], "\r\n") {
+				i++
+			}
+			return string(row), i + 1
+		}
+		end := i + strings.IndexAny(s[i:], " \t\r\n")
+		if end < i {
+			end = len(s)
+		}
+		word := []rune(s[i:end])
+		switch {
+		case len(row) == 0 && len(word) > width:
+			// Split the word, counting the bytes of what fits.
+			return string(word[:width]), i + len(string(word[:width]))
+		case len(row) > 0 && len(row)+1+len(word) > width:
+			return string(row), i
+		case len(row) > 0:
+			row = append(row, ' ')
+		}
+		row = append(row, word...)
+		i = end
+	}
+}
+
+// alignText pads or truncates text to exactly width runes.
+func alignText(text string, width int, align Alignment) string {
+	r := []rune(text)
+	if len(r) >= width {
+		return string(r[:width])
+	}
+	pad := width - len(r)
+	var left int
+	switch align {
+	case AlignCenter:
+		left = pad / 2
+	case AlignRight:
+		left = pad
+	}
+	return strings.Repeat(" ", left) + text + strings.Repeat(" ", pad-left)
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 text helpers
+*/
+package lcd1602
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrintf(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Opts
+		line, col byte
+		format    string
+		args      []interface{}
+		want      []op
+	}{
+		{"fits", DefaultOpts, 1, 0, "T=%d", []interface{}{21}, append([]op{cmd(CMD_DDRAM_Set | 0x00)}, text("T=21")...)},
+		{"truncated", DefaultOpts, 2, 12, "%06d", []interface{}{123456}, append([]op{cmd(CMD_DDRAM_Set | 0x4C)}, text("1234")...)},
+		{"last cell", DefaultOpts, 1, 15, "%s", []interface{}{"xyz"}, append([]op{cmd(CMD_DDRAM_Set | 0x0F)}, text("x")...)},
+		{"20x4 line 3", Opts20x4, 3, 17, "%.1f", []interface{}{12.5}, append([]op{cmd(CMD_DDRAM_Set | 0x25)}, text("12.")...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, tt.opts)
+			if err := d.Printf(tt.line, tt.col, tt.format, tt.args...); err != nil {
+				t.Fatal(err)
+			}
+			if got := latched(t, d, r.take()); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearLine(t *testing.T) {
+	starts := []byte{0x00, 0x40, 0x14, 0x54}
+	for i, start := range starts {
+		line := byte(i + 1)
+		d, r, _ := newReadyDev(t, Opts20x4)
+		if err := d.ClearLine(line); err != nil {
+			t.Fatal(err)
+		}
+		want := []op{cmd(CMD_DDRAM_Set | start)}
+		want = append(want, text(strings.Repeat(" ", 20))...)
+		want = append(want, cmd(CMD_DDRAM_Set|start))
+		if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+			t.Errorf("ClearLine(%d) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestWriteAligned(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		align Alignment
+		want  string
+	}{
+		{"centre even padding", "ab", AlignCenter, "       ab       "},
+		{"centre odd padding", "abc", AlignCenter, "      abc       "},
+		{"centre full", "0123456789abcdef", AlignCenter, "0123456789abcdef"},
+		{"centre too long", "0123456789abcdefgh", AlignCenter, "0123456789abcdef"},
+		{"right", "abc", AlignRight, "             abc"},
+		{"left", "abc", AlignLeft, "abc             "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, DefaultOpts)
+			if err := d.WriteAligned(2, tt.text, tt.align); err != nil {
+				t.Fatal(err)
+			}
+			want := append([]op{cmd(CMD_DDRAM_Set | 0x40)}, text(tt.want)...)
+			if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	const s = "0123456789abcdefghijklmnopqrstuvwxyzABCD"
+	blank, blank20 := strings.Repeat(" ", 16), strings.Repeat(" ", 20)
+	tests := []struct {
+		name    string
+		opts    Opts
+		policy  OverflowPolicy
+		n       []int // lengths of successive writes of s
+		want    string
+		wantN   int // from the last write
+		wantErr error
+	}{
+		{"truncate under", DefaultOpts, OverflowTruncate, []int{10}, "0123456789      \n" + blank, 10, nil},
+		{"truncate fill", DefaultOpts, OverflowTruncate, []int{16}, s[:16] + "\n" + blank, 16, nil},
+		{"truncate over", DefaultOpts, OverflowTruncate, []int{20}, s[:16] + "\n" + blank, 20, nil},
+		{"truncate fill then more", DefaultOpts, OverflowTruncate, []int{16, 3}, s[:16] + "\n" + blank, 3, nil},
+		{"wrap under", DefaultOpts, OverflowWrap, []int{20}, s[:16] + "\n" + s[16:20] + strings.Repeat(" ", 12), 20, nil},
+		{"wrap fill", DefaultOpts, OverflowWrap, []int{32}, s[:16] + "\n" + s[16:32], 32, nil},
+		{"wrap over", DefaultOpts, OverflowWrap, []int{40}, s[:16] + "\n" + s[16:32], 32, io.ErrShortWrite},
+		{"wrap fill then more", DefaultOpts, OverflowWrap, []int{16, 3}, s[:16] + "\n" + s[16:19] + strings.Repeat(" ", 13), 3, nil},
+		// The rest goes to the off-screen DDRAM after line 1.
+		{"raw over", DefaultOpts, OverflowRaw, []int{40}, s[:16] + "\n" + blank, 40, nil},
+		// Line 1 runs on to 0x14, which is also the start of line 3.
+		{"20x4 truncate fill then more", Opts20x4, OverflowTruncate, []int{20, 3}, s[:20] + "\n" + blank20 + "\n" + blank20 + "\n" + blank20, 3, nil},
+		{"20x4 wrap fill then more", Opts20x4, OverflowWrap, []int{20, 3}, s[:20] + "\n" + s[20:23] + strings.Repeat(" ", 17) + "\n" + blank20 + "\n" + blank20, 3, nil},
+	}
+	writes := []struct {
+		name  string
+		write func(d *Dev, s string) (int, error)
+	}{
+		{"WriteString", (*Dev).WriteString},
+		{"Write", func(d *Dev, s string) (int, error) { return d.Write([]byte(s)) }},
+	}
+	for _, w := range writes {
+		for _, tt := range tests {
+			t.Run(w.name+" "+tt.name, func(t *testing.T) {
+				o := tt.opts
+				o.Overflow = tt.policy
+				d, _, _ := newReadyDev(t, o)
+				var n, from int
+				var err error
+				for _, l := range tt.n {
+					n, err = w.write(d, s[from:from+l])
+					from += l
+				}
+				if n != tt.wantN || err != tt.wantErr {
+					t.Errorf("%s = %d, %v, want %d, %v", w.name, n, err, tt.wantN, tt.wantErr)
+				}
+				if got := d.Snapshot(); got != tt.want {
+					t.Errorf("screen\n%s\nwant\n%s", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestBackspaceAfterFill(t *testing.T) {
+	// Filling line 1 of a 20x4 leaves the counter at 0x14, the start of
+	// line 3, but the cell to blank is the last of line 1.
+	d, r, _ := newReadyDev(t, Opts20x4)
+	if _, err := d.WriteString(strings.Repeat("x", 20)); err != nil {
+		t.Fatal(err)
+	}
+	r.take()
+	if err := d.Backspace(); err != nil {
+		t.Fatal(err)
+	}
+	want := []op{cmd(CMD_DDRAM_Set | 0x13), {data: true, b: ' '}, cmd(CMD_DDRAM_Set | 0x13)}
+	if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWriteLineOverwrites(t *testing.T) {
+	d, r, _ := newReadyDev(t, DefaultOpts)
+	if err := d.WriteLine(2, "a much longer one"); err != nil {
+		t.Fatal(err)
+	}
+	r.take()
+	if err := d.WriteLine(2, "short"); err != nil {
+		t.Fatal(err)
+	}
+	want := []op{cmd(CMD_DDRAM_Set | 0x40)}
+	want = append(want, text("short           ")...)
+	want = append(want, cmd(CMD_DDRAM_Set|0x40))
+	if got := latched(t, d, r.take()); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := d.Snapshot(), strings.Repeat(" ", 16)+"\nshort           "; got != want {
+		t.Errorf("screen %q, want %q", got, want)
+	}
+}
+
+func TestWriteStringAfterFullLine(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowTruncate, OverflowWrap} {
+		o := DefaultOpts
+		o.Overflow = policy
+		d, _, _ := newReadyDev(t, o)
+		if _, err := d.WriteString("0123456789abcdef"); err != nil {
+			t.Fatal(err)
+		}
+		// The cursor is now just past line 1, off screen.
+		if n, err := d.WriteString("\nline2"); n != 6 || err != nil {
+			t.Errorf("policy %d: WriteString after a full line = %d, %v, want 6, nil", policy, n, err)
+		}
+		if got, want := d.Snapshot(), "0123456789abcdef\nline2           "; got != want {
+			t.Errorf("policy %d: screen %q, want %q", policy, got, want)
+		}
+	}
+	// Without a newline the text runs on as the policy says.
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	if _, err := d.WriteString("0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.WriteString("more"); n != 4 || err != nil {
+		t.Errorf("truncated WriteString after a full line = %d, %v, want 4, nil", n, err)
+	}
+}
+
+// TestGeometryUnderLock is meant for -race: the helpers that check the line
+// count must not read it while SetLines changes it.
+func TestGeometryUnderLock(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := d.SetLines(byte(1 + i%2)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		// Either may fail while the display has one line.
+		d.WriteTitle("title", "sub")
+		NewBigDigits(d)
+	}
+	wg.Wait()
+}
+
+func TestPositionedWritesDecrement(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(d *Dev) error
+	}{
+		{"WriteLine", func(d *Dev) error { return d.WriteLine(1, "Hello") }},
+		{"WriteReplace", func(d *Dev) error { return d.WriteReplace(1, "Hello") }},
+		{"Render", func(d *Dev) error { return d.Render([][]rune{[]rune("Hello")}) }},
+		{"Flush", func(d *Dev) error {
+			// Batched writes run right to left like the real ones.
+			d.BeginBatch()
+			if err := d.SetPosition(1, 4); err != nil {
+				return err
+			}
+			if _, err := d.WriteString("olleH"); err != nil {
+				return err
+			}
+			return d.Flush()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, _ := newReadyDev(t, DefaultOpts)
+			if err := d.SetIncrement(false); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.do(d); err != nil {
+				t.Fatal(err)
+			}
+			m := newDDRAM()
+			m.apply(latched(t, d, r.take()))
+			if got, want := m.row(0x00, 16), "Hello           "; got != want {
+				t.Errorf("line 1 DDRAM = %q, want %q", got, want)
+			}
+			if !m.dec {
+				t.Error("entry mode left incrementing")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 Tim St. Pierre
+Character themes for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"fmt"
+)
+
+// Theme bundles everything that decides how runes turn into pixels: custom
+// glyphs for CGRAM, the rune translation and the character ROM, so a whole
+// look can be switched with one ApplyTheme.
+type Theme struct {
+	// Loaded into CGRAM slots 0 on, at most CGRAMSlots of them. Map runes
+	// to their slot numbers in CharMap to print them with WriteString.
+	Glyphs [][8]byte
+	// As Opts.CharMap, Opts.RomVariant and Opts.SubstituteByte.
+	CharMap        map[rune]byte
+	RomVariant     RomVariant
+	SubstituteByte byte
+}
+
+// ThemeWeather holds weather icons for A00 parts: ☀ sun, ☁ cloud, 🌧 rain,
+// ❄ snow, ⚡ lightning, 💧 humidity, 🌡 temperature and 🌬 wind.
+var ThemeWeather = Theme{
+	Glyphs: [][8]byte{
+		{0x04, 0x15, 0x0E, 0x1B, 0x0E, 0x15, 0x04, 0x00}, // sun
+		{0x00, 0x00, 0x0C, 0x1E, 0x1F, 0x1F, 0x00, 0x00}, // cloud
+		{0x0E, 0x1F, 0x1F, 0x00, 0x15, 0x00, 0x0A, 0x00}, // rain
+		{0x04, 0x15, 0x0E, 0x04, 0x0E, 0x15, 0x04, 0x00}, // snow
+		{0x03, 0x06, 0x0C, 0x1F, 0x06, 0x0C, 0x08, 0x00}, // lightning
+		{0x04, 0x04, 0x0E, 0x0E, 0x1F, 0x1F, 0x0E, 0x00}, // droplet
+		{0x04, 0x0A, 0x0A, 0x0A, 0x0E, 0x1F, 0x1F, 0x0E}, // thermometer
+		{0x00, 0x1E, 0x01, 0x1E, 0x00, 0x1C, 0x02, 0x1C}, // wind
+	},
+	CharMap: themeCharMap(map[rune]byte{
+		'☀': 0, '☁': 1, '🌧': 2, '❄': 3, '⚡': 4, '💧': 5, '🌡': 6, '🌬': 7,
+	}),
+}
+
+// ThemeUI holds menu and status symbols for A00 parts: ↑ and ↓ arrows, ✓
+// tick, ✗ cross, ♥ heart, 🔔 bell, 🔒 lock and ⏎ return.
+var ThemeUI = Theme{
+	Glyphs: [][8]byte{
+		{0x04, 0x0E, 0x15, 0x04, 0x04, 0x04, 0x04, 0x00}, // up arrow
+		{0x04, 0x04, 0x04, 0x04, 0x15, 0x0E, 0x04, 0x00}, // down arrow
+		{0x00, 0x01, 0x03, 0x16, 0x1C, 0x08, 0x00, 0x00}, // tick
+		{0x00, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x00, 0x00}, // cross
+		{0x00, 0x0A, 0x1F, 0x1F, 0x0E, 0x04, 0x00, 0x00}, // heart
+		{0x04, 0x0E, 0x0E, 0x0E, 0x1F, 0x00, 0x04, 0x00}, // bell
+		{0x0E, 0x11, 0x11, 0x1F, 0x1B, 0x1B, 0x1F, 0x00}, // lock
+		{0x01, 0x01, 0x05, 0x09, 0x1F, 0x08, 0x04, 0x00}, // return
+	},
+	CharMap: themeCharMap(map[rune]byte{
+		'↑': 0, '↓': 1, '✓': 2, '✗': 3, '♥': 4, '🔔': 5, '🔒': 6, '⏎': 7,
+	}),
+}
+
+// themeCharMap returns A00CharMap with glyphs added, so a theme keeps the
+// usual look-alikes.
+func themeCharMap(glyphs map[rune]byte) map[rune]byte {
+	m := make(map[rune]byte, len(A00CharMap)+len(glyphs))
+	for r, b := range A00CharMap {
+		m[r] = b
+	}
+	for r, b := range glyphs {
+		m[r] = b
+	}
+	return m
+}
+
+// ApplyTheme loads t's glyphs into CGRAM, marking their slots taken as
+// CreateChar does, and switches to its translation and ROM. Slots past the
+// glyphs are left alone. Text already on screen is not redrawn.
+func (d *Dev) ApplyTheme(t Theme) error {
+	if len(t.Glyphs) > CGRAMSlots {
+		return fmt.Errorf("lcd1602: theme has %d glyphs, CGRAM holds %d", len(t.Glyphs), CGRAMSlots)
+	}
+	for slot, pattern := range t.Glyphs {
+		for i, row := range pattern {
+			if row > 0x1F {
+				return fmt.Errorf("lcd1602: glyph %d row %d is %#x, only 5 bits wide", slot, i, row)
+			}
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for slot, pattern := range t.Glyphs {
+		if err := d.createChar(byte(slot), pattern, cgramUser); err != nil {
+			return err
+		}
+	}
+	d.opts.CharMap = t.CharMap
+	d.opts.RomVariant = t.RomVariant
+	d.opts.SubstituteByte = t.SubstituteByte
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Tim St. Pierre
+Sub-line regions for lcd1602 character display
+*/
+package lcd1602
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Viewport is a fixed run of cells on one line that can be written and
+// cleared without touching the rest of the line, e.g. a value field next to
+// a label. It keeps its own cursor, from 0 to its width.
+type Viewport struct {
+	d     *Dev
+	line  byte
+	col   byte
+	width byte
+	pos   byte
+}
+
+// NewViewport returns a viewport of width cells starting at col on line.
+// The width is cut short if it would run past the end of the line.
+func NewViewport(dev *Dev, line, col, width byte) *Viewport {
+	if col >= dev.opts.Cols {
+		width = 0
+	} else if room := dev.opts.Cols - col; width > room {
+		width = room
+	}
+	return &Viewport{d: dev, line: line, col: col, width: width}
+}
+
+// Width returns the number of cells in the viewport.
+func (v *Viewport) Width() byte {
+	return v.width
+}
+
+// SetPosition moves the viewport cursor to pos, counted from its first cell.
+func (v *Viewport) SetPosition(pos byte) error {
+	if pos >= v.width {
+		return fmt.Errorf("lcd1602: viewport position %d out of range 0-%d", pos, int(v.width)-1)
+	}
+	v.pos = pos
+	return nil
+}
+
+// Write writes raw bytes at the viewport cursor. Bytes that do not fit are
+// dropped and io.ErrShortWrite returned.
+func (v *Viewport) Write(buf []byte) (int, error) {
+	n := len(buf)
+	if room := int(v.width - v.pos); n > room {
+		n = room
+	}
+	if written, err := v.put(v.pos, func() []byte { return buf[:n] }); err != nil {
+		return written, err
+	}
+	if n < len(buf) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// WriteString translates s like Dev.WriteString and writes it at the
+// viewport cursor, clipped to the viewport. It returns the bytes consumed.
+func (v *Viewport) WriteString(s string) (int, error) {
+	var rs []rune
+	var starts []int // byte offset of each rune in rs
+	consumed := len(s)
+	for i, r := range s {
+		if len(rs) == int(v.width-v.pos) {
+			consumed = i
+			break
+		}
+		rs = append(rs, r)
+		starts = append(starts, i)
+	}
+	// The character map may be changed by ApplyTheme at any time, so it is
+	// only read under the driver's lock.
+	written, err := v.put(v.pos, func() []byte {
+		cells := make([]byte, len(rs))
+		for i, r := range rs {
+			cells[i] = v.d.mapRune(r)
+		}
+		return cells
+	})
+	if err != nil {
+		if written < len(starts) {
+			return starts[written], err
+		}
+		return consumed, err
+	}
+	if consumed < len(s) {
+		return consumed, io.ErrShortWrite
+	}
+	return consumed, nil
+}
+
+// Clear blanks the viewport's cells and moves its cursor to the start.
+func (v *Viewport) Clear() error {
+	if v.width > 0 {
+		if _, err := v.put(0, func() []byte { return bytes.Repeat([]byte{' '}, int(v.width)) }); err != nil {
+			return err
+		}
+	}
+	v.pos = 0
+	return nil
+}
+
+// put writes the cells returned by cells, which is called with the driver's
+// lock held, from viewport position pos, and leaves the viewport cursor after
+// the last cell written. It returns the number of cells written.
+func (v *Viewport) put(pos byte, cells func() []byte) (int, error) {
+	v.d.mu.Lock()
+	defer v.d.mu.Unlock()
+	buf := cells()
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if err := v.d.setPosition(v.line, v.col+pos); err != nil {
+		return 0, err
+	}
+	n, err := v.d.writeData(buf)
+	v.pos = pos + byte(n)
+	return n, err
+}
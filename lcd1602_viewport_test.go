@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Tim St. Pierre
+Tests for lcd1602 viewports
+*/
+package lcd1602
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestViewportWriteString(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	if err := d.ApplyTheme(ThemeUI); err != nil {
+		t.Fatal(err)
+	}
+	v := NewViewport(d, 2, 10, 4)
+	if n, err := v.WriteString("♥ok"); n != len("♥ok") || err != nil {
+		t.Fatalf("WriteString = %d, %v", n, err)
+	}
+	if got, want := d.Snapshot()[17:], "          \x04ok   "; got != want {
+		t.Errorf("line 2 = %q, want %q", got, want)
+	}
+}
+
+// TestViewportTheme is meant for -race: the viewport translates text while
+// the theme changes under it.
+func TestViewportTheme(t *testing.T) {
+	d, _, _ := newReadyDev(t, DefaultOpts)
+	v := NewViewport(d, 1, 0, 4)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			theme := ThemeUI
+			if i%2 == 1 {
+				theme = ThemeWeather
+			}
+			if err := d.ApplyTheme(theme); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := v.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.WriteString("♥☀"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+// choke is a recorder that refuses every write after the next left, or
+// none while left is negative.
+type choke struct {
+	recorder
+	left int
+}
+
+func (c *choke) writeByte(b byte) error {
+	if c.left == 0 {
+		return errors.New("nack")
+	}
+	c.left--
+	return c.recorder.writeByte(b)
+}
+
+func TestViewportShortWrite(t *testing.T) {
+	writes := []struct {
+		name  string
+		write func(v *Viewport) (int, error)
+		want  int
+	}{
+		{"Write", func(v *Viewport) (int, error) { return v.Write([]byte("abc")) }, 2},
+		{"WriteString", func(v *Viewport) (int, error) { return v.WriteString("°bc") }, len("°b")},
+	}
+	for _, w := range writes {
+		t.Run(w.name, func(t *testing.T) {
+			c := &choke{left: -1}
+			d, _ := newTestDevOn(t, c, DefaultOpts)
+			v := NewViewport(d, 1, 0, 8)
+			// The positioning command and two characters, six bytes each,
+			// get through.
+			c.left = 3 * 6
+			n, err := w.write(v)
+			if n != w.want || !errors.Is(err, ErrBusWrite) {
+				t.Errorf("%s = %d, %v, want %d, ErrBusWrite", w.name, n, err, w.want)
+			}
+			if v.pos != 2 {
+				t.Errorf("cursor at %d, want 2", v.pos)
+			}
+		})
+	}
+}